@@ -0,0 +1,152 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Directory is a local directory whose files are indexed.
+type Directory struct {
+	Path string `yaml:"path" mapstructure:"path"`
+	// Include lists glob patterns of the indexed files, every supported file is indexed if it is empty
+	Include []string `yaml:"include" mapstructure:"include"`
+	// Exclude lists glob patterns of skipped files and directories
+	Exclude []string `yaml:"exclude" mapstructure:"exclude"`
+	// Watch keeps the index in sync with the directory while the server is running
+	Watch   bool `yaml:"watch" mapstructure:"watch"`
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// NewDirectory creates a validated directory configuration.
+func NewDirectory(path string, include, exclude []string) (*Directory, error) {
+	d := &Directory{
+		Path:    path,
+		Include: include,
+		Exclude: exclude,
+	}
+	return d, d.init()
+}
+
+func (d *Directory) init() error {
+	if d.Path == "" {
+		return errors.New("missing directory path")
+	}
+	if strings.HasPrefix(d.Path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		d.Path = filepath.Join(home, d.Path[2:])
+	}
+	p, err := filepath.Abs(d.Path)
+	if err != nil {
+		return err
+	}
+	d.Path = p
+	d.include, err = compileGlobs(d.Include)
+	if err != nil {
+		return err
+	}
+	d.exclude, err = compileGlobs(d.Exclude)
+	return err
+}
+
+// Included reports whether a file should be indexed.
+// The path is relative to the directory and uses forward slashes.
+func (d *Directory) Included(rel string) bool {
+	if d.Excluded(rel) {
+		return false
+	}
+	if len(d.include) == 0 {
+		return true
+	}
+	return matchGlobs(d.include, rel)
+}
+
+// Excluded reports whether a file or a directory is skipped.
+// Hidden files and directories are always skipped.
+func (d *Directory) Excluded(rel string) bool {
+	for _, p := range strings.Split(rel, "/") {
+		if strings.HasPrefix(p, ".") && p != "." {
+			return true
+		}
+	}
+	return matchGlobs(d.exclude, rel)
+}
+
+func matchGlobs(res []*regexp.Regexp, rel string) bool {
+	for _, re := range res {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileGlobs(globs []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(globs))
+	for _, g := range globs {
+		re, err := globToRegexp(g)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %w", g, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// globToRegexp converts glob patterns to regular expressions.
+// `*` and `?` don't match path separators, `**` matches any number of directories.
+// Patterns without a slash match the name of the file at any depth.
+func globToRegexp(g string) (*regexp.Regexp, error) {
+	if g == "" {
+		return nil, errors.New("empty pattern")
+	}
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !strings.Contains(g, "/") {
+		sb.WriteString("(?:.*/)?")
+	}
+	r := []rune(strings.TrimPrefix(g, "/"))
+	for i := 0; i < len(r); i++ {
+		switch c := r[i]; c {
+		case '*':
+			if i+1 < len(r) && r[i+1] == '*' {
+				i++
+				if i+1 < len(r) && r[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// directory patterns match their content too
+	sb.WriteString("(?:/.*)?$")
+	return regexp.Compile(sb.String())
+}
+
+func (c *Config) validateDirectories() error {
+	for _, d := range c.Directories {
+		if err := d.init(); err != nil {
+			return fmt.Errorf("invalid directory configuration: %w", err)
+		}
+	}
+	return nil
+}
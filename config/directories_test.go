@@ -0,0 +1,84 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package config
+
+import (
+	"testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob    string
+		match   []string
+		noMatch []string
+	}{
+		{
+			glob:    "*.md",
+			match:   []string{"a.md", "docs/a.md", "docs/sub/.md"},
+			noMatch: []string{"a.mdx", "a.md.txt", "amd"},
+		},
+		{
+			glob:    "node_modules",
+			match:   []string{"node_modules", "a/node_modules", "a/node_modules/b/c.js"},
+			noMatch: []string{"node_modules2", "my_node_modules"},
+		},
+		{
+			glob:    "docs/*.txt",
+			match:   []string{"docs/a.txt"},
+			noMatch: []string{"docs/sub/a.txt", "a/docs/a.txt", "docs.txt"},
+		},
+		{
+			glob:    "/build",
+			match:   []string{"build", "build/out.o"},
+			noMatch: []string{"src/build", "builds"},
+		},
+		{
+			glob:    "docs/**/*.md",
+			match:   []string{"docs/a.md", "docs/sub/a.md", "docs/sub/deeper/a.md"},
+			noMatch: []string{"a.md", "other/docs/a.md"},
+		},
+		{
+			glob:    "docs/**",
+			match:   []string{"docs/a", "docs/sub/a.md"},
+			noMatch: []string{"a/docs/b"},
+		},
+		{
+			glob:    "?.go",
+			match:   []string{"a.go", "pkg/b.go"},
+			noMatch: []string{"ab.go", ".go"},
+		},
+		{
+			glob:    "notes (old)/[draft].md",
+			match:   []string{"notes (old)/[draft].md"},
+			noMatch: []string{"notes (old)/d.md", "notes old/[draft].md"},
+		},
+		{
+			glob:    "jegyzetek/*.md",
+			match:   []string{"jegyzetek/ő.md"},
+			noMatch: []string{"jegyzetek/ő/a.txt"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.glob, func(t *testing.T) {
+			re, err := globToRegexp(tc.glob)
+			if err != nil {
+				t.Fatalf("globToRegexp(%q) error = %v", tc.glob, err)
+			}
+			for _, p := range tc.match {
+				if !re.MatchString(p) {
+					t.Errorf("%q (%s) doesn't match %q", tc.glob, re, p)
+				}
+			}
+			for _, p := range tc.noMatch {
+				if re.MatchString(p) {
+					t.Errorf("%q (%s) matches %q", tc.glob, re, p)
+				}
+			}
+		})
+	}
+	if _, err := globToRegexp(""); err == nil {
+		t.Error("globToRegexp(\"\") error = nil, want error")
+	}
+}
@@ -0,0 +1,121 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package config
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// URLNormalization describes how URLs are canonicalized before indexing and lookups.
+type URLNormalization struct {
+	// DropParams lists query parameters to remove. A trailing "*" matches parameter prefixes.
+	DropParams         []string               `yaml:"drop_params" mapstructure:"drop_params"`
+	DropFragment       bool                   `yaml:"drop_fragment" mapstructure:"drop_fragment"`
+	StripTrailingSlash bool                   `yaml:"strip_trailing_slash" mapstructure:"strip_trailing_slash"`
+	StripWWW           bool                   `yaml:"strip_www" mapstructure:"strip_www"`
+	ForceHTTPS         bool                   `yaml:"force_https" mapstructure:"force_https"`
+	UseCanonical       bool                   `yaml:"use_canonical" mapstructure:"use_canonical"`
+	Domains            []*DomainNormalization `yaml:"domains" mapstructure:"domains"`
+}
+
+// DomainNormalization overrides the query parameter rules of a domain and its subdomains.
+type DomainNormalization struct {
+	Domain string `yaml:"domain" mapstructure:"domain"`
+	// KeepParams are never dropped, even if they match the global DropParams
+	KeepParams []string `yaml:"keep_params" mapstructure:"keep_params"`
+	// DropParams are dropped in addition to the global DropParams
+	DropParams []string `yaml:"drop_params" mapstructure:"drop_params"`
+}
+
+func defaultURLNormalization() URLNormalization {
+	return URLNormalization{
+		DropParams: []string{
+			"utm",
+			"utm_*",
+			"fbclid",
+			"gclid",
+			"dclid",
+			"msclkid",
+			"yclid",
+			"igshid",
+			"mc_eid",
+			"mc_cid",
+			"_hsenc",
+			"_hsmi",
+			"ref",
+			"ref_src",
+		},
+		DropFragment: true,
+		Domains: []*DomainNormalization{
+			{Domain: "github.com", KeepParams: []string{"ref"}},
+			{Domain: "gitlab.com", KeepParams: []string{"ref"}},
+		},
+	}
+}
+
+// Normalize returns the canonical form of an URL.
+// Non-HTTP URLs and URLs that can't be parsed are returned unchanged.
+func (n *URLNormalization) Normalize(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil || (pu.Scheme != "http" && pu.Scheme != "https") || pu.Host == "" {
+		return u
+	}
+	pu.Host = strings.ToLower(pu.Host)
+	if n.ForceHTTPS && pu.Scheme == "http" {
+		pu.Scheme = "https"
+		if pu.Port() == "80" {
+			pu.Host = pu.Hostname()
+		}
+	}
+	if n.StripWWW {
+		pu.Host = strings.TrimPrefix(pu.Host, "www.")
+	}
+	if n.DropFragment {
+		pu.Fragment = ""
+		pu.RawFragment = ""
+	}
+	if n.StripTrailingSlash && strings.HasSuffix(pu.Path, "/") {
+		pu.Path = strings.TrimRight(pu.Path, "/")
+		pu.RawPath = ""
+	}
+	if pu.RawQuery != "" {
+		dr := n.domainRule(pu.Hostname())
+		q := pu.Query()
+		changed := false
+		for k := range q {
+			if dr != nil && matchParam(dr.KeepParams, k) {
+				continue
+			}
+			if matchParam(n.DropParams, k) || (dr != nil && matchParam(dr.DropParams, k)) {
+				q.Del(k)
+				changed = true
+			}
+		}
+		if changed {
+			pu.RawQuery = q.Encode()
+		}
+	}
+	return pu.String()
+}
+
+func (n *URLNormalization) domainRule(host string) *DomainNormalization {
+	for _, d := range n.Domains {
+		if host == d.Domain || strings.HasSuffix(host, "."+d.Domain) {
+			return d
+		}
+	}
+	return nil
+}
+
+func matchParam(patterns []string, k string) bool {
+	return slices.ContainsFunc(patterns, func(p string) bool {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			return strings.HasPrefix(k, prefix)
+		}
+		return p == k
+	})
+}
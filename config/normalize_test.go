@@ -0,0 +1,50 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package config
+
+import (
+	"testing"
+)
+
+func TestURLNormalizationNormalize(t *testing.T) {
+	all := defaultURLNormalization()
+	all.StripTrailingSlash = true
+	all.StripWWW = true
+	all.ForceHTTPS = true
+	all.Domains = append(all.Domains, &DomainNormalization{Domain: "example.org", DropParams: []string{"session"}})
+	def := defaultURLNormalization()
+	tests := []struct {
+		name string
+		n    *URLNormalization
+		url  string
+		want string
+	}{
+		{"unchanged", &def, "https://example.com/a?q=1", "https://example.com/a?q=1"},
+		{"lowercase host", &def, "https://Example.COM/Path", "https://example.com/Path"},
+		{"tracking params", &def, "https://example.com/a?utm_source=x&q=1&fbclid=y&utm=z", "https://example.com/a?q=1"},
+		{"all params dropped", &def, "https://example.com/a?utm_medium=x", "https://example.com/a"},
+		{"fragment", &def, "https://example.com/a#section", "https://example.com/a"},
+		{"kept fragment", &URLNormalization{}, "https://example.com/a#section", "https://example.com/a#section"},
+		{"domain keep params", &def, "https://github.com/a/b?ref=main&utm_source=x", "https://github.com/a/b?ref=main"},
+		{"subdomain keep params", &def, "https://docs.github.com/a?ref=main", "https://docs.github.com/a?ref=main"},
+		{"ref dropped elsewhere", &def, "https://example.com/a?ref=main", "https://example.com/a"},
+		{"domain drop params", &all, "https://example.org/a?session=1&q=2", "https://example.org/a?q=2"},
+		{"trailing slash", &all, "https://example.com/a/", "https://example.com/a"},
+		{"root slash", &all, "https://example.com/", "https://example.com"},
+		{"www", &all, "https://www.example.com/a", "https://example.com/a"},
+		{"force https", &all, "http://example.com:80/a", "https://example.com/a"},
+		{"force https keeps other ports", &all, "http://example.com:8080/a", "https://example.com:8080/a"},
+		{"non http", &all, "file:///home/user/a/", "file:///home/user/a/"},
+		{"invalid", &all, "https://exa mple.com/%zz", "https://exa mple.com/%zz"},
+		{"relative", &all, "/a/b/", "/a/b/"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.n.Normalize(tc.url); got != tc.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tc.url, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// SensitiveReject drops documents matching the pattern
+	SensitiveReject = "reject"
+	// SensitiveRedact replaces the matches of the pattern with a placeholder
+	SensitiveRedact = "redact"
+	// SensitiveAllowOnDomains accepts matches on the listed domains and rejects them elsewhere
+	SensitiveAllowOnDomains = "allow-on-domains"
+)
+
+// SensitiveContentAction defines how the matches of a sensitive content pattern are handled.
+type SensitiveContentAction struct {
+	Action  string   `yaml:"action" mapstructure:"action"`
+	Domains []string `yaml:"domains" mapstructure:"domains"`
+}
+
+// SensitiveContentAction returns the action of a sensitive content pattern.
+// Patterns without configured action are rejected.
+func (c *Config) SensitiveContentAction(name string) *SensitiveContentAction {
+	if a, ok := c.SensitiveContentActions[name]; ok && a != nil {
+		return a
+	}
+	return &SensitiveContentAction{Action: SensitiveReject}
+}
+
+// AllowedOn reports whether the action accepts matches on the given host.
+func (a *SensitiveContentAction) AllowedOn(host string) bool {
+	if a.Action != SensitiveAllowOnDomains {
+		return false
+	}
+	host = strings.ToLower(host)
+	for _, d := range a.Domains {
+		d = strings.ToLower(d)
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) validateSensitiveContent() error {
+	for name, p := range c.SensitiveContentPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid sensitive content pattern %q: %w", name, err)
+		}
+	}
+	for name, a := range c.SensitiveContentActions {
+		if _, ok := c.SensitiveContentPatterns[name]; !ok {
+			return errors.New("sensitive content action for unknown pattern: " + name)
+		}
+		if a == nil {
+			continue
+		}
+		switch a.Action {
+		case SensitiveReject, SensitiveRedact:
+		case SensitiveAllowOnDomains:
+			if len(a.Domains) == 0 {
+				return errors.New("missing domains of sensitive content action: " + name)
+			}
+		default:
+			return fmt.Errorf("unknown sensitive content action %q of pattern %q", a.Action, name)
+		}
+	}
+	return nil
+}
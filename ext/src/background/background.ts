@@ -16,7 +16,16 @@ function cjsMsgHandler(request, sender, sendResponse) {
             u += '/';
         }
         if(request.pageData) {
-            sendPageData(u+"add", request.pageData).then((r) => sendResponse({"status": "ok", "status_code": r.status})).catch(err => sendResponse({"error": err.message}));
+            sendPageData(u+"add", request.pageData).then(async (r) => {
+                let resp = {"status": "ok", "status_code": r.status, "sensitive": []};
+                try {
+                    let body = await r.json();
+                    resp.sensitive = body.sensitive || [];
+                } catch(e) {
+                    // responses without JSON body
+                }
+                sendResponse(resp);
+            }).catch(err => sendResponse({"error": err.message}));
             return true;
         }
         if(request.resultData) {
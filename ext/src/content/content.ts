@@ -38,6 +38,9 @@ function extract(sendResponse) {
             if(typeof sendResponse === 'function') {
                 sendResponse(resp);
             }
+            if(resp && resp.sensitive && resp.sensitive.length) {
+                console.log("sensitive content found on page", resp.sensitive);
+            }
             if(!resp || (resp.error || resp.status_code != 201)) {
                 console.log("failed to submit page data, stopping extraction", resp);
                 return;
@@ -4,6 +4,7 @@ type PageData = {
     url: string;
     html: string;
     faviconURL: string;
+    referrer: string;
 }
 
 type Result = {
@@ -88,6 +89,7 @@ function extractPageData() : PageData{
         url: getURL(),
         html: document.documentElement.innerHTML,
         faviconURL: new URL("/favicon.ico", getURL()).href,
+        referrer: document.referrer,
     };
 	let link = document.querySelector("link[rel~='icon']");
 	if (link && link.getAttribute("href")) {
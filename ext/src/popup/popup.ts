@@ -32,12 +32,23 @@ chrome.storage.local.get(['histerURL'], (d) => {
     urlInput.setAttribute('value', d['histerURL'] || defaultURL);
 });
 
+function sensitivePatterns(matches) {
+    return matches.map(m => m.pattern + " (" + m.action + ")").join(", ");
+}
+
 document.querySelector("#reindex").addEventListener("click", (e) => {
 	chrome.tabs.query({active: true, currentWindow: true}, function(tabs) {
 		if(!tabs) return;
 		chrome.tabs.sendMessage(tabs[0].id, {action: "reindex"}, (r) => {
+            if(r && r.status_code == 422) {
+                msgBox.innerText = "Reindex failed: page contains sensitive content (" + sensitivePatterns(r.sensitive || []) + ")";
+                return;
+            }
             if(r && r.status == "ok") {
                 msgBox.innerText = "Reindex successful";
+                if(r.sensitive && r.sensitive.length) {
+                    msgBox.innerText += "\nSensitive content found: " + sensitivePatterns(r.sensitive);
+                }
                 return;
             }
             msgBox.innerText = "Reindex failed";
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -64,6 +66,9 @@ var listenCmd = &cobra.Command{
 	},
 	Run: func(cmd *cobra.Command, _ []string) {
 		setStrArg(cmd, "address", &cfg.Server.Address)
+		if err := indexer.WatchDirectories(cfg.Directories); err != nil {
+			log.Error().Err(err).Msg("Failed to watch directories")
+		}
 		server.Listen(cfg)
 	},
 }
@@ -123,16 +128,26 @@ var searchCmd = &cobra.Command{
 	Short: "Command line search interface",
 	Long:  "Command line search interface.\nRun it without arguments to use the TUI interface or pass search terms as arguments to get results on the STDOUT.",
 	Args:  cobra.MinimumNArgs(0),
-	Run: func(_ *cobra.Command, args []string) {
+	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) == 0 {
 			if err := ui.SearchTUI(cfg); err != nil {
 				exit(1, err.Error())
 			}
 			return
 		}
-		qs := strings.Join(args, " ")
+		params := url.Values{"q": {strings.Join(args, " ")}}
+		for _, f := range []string{"limit", "offset"} {
+			if n, err := cmd.Flags().GetInt(f); err == nil && n > 0 {
+				params.Set(f, strconv.Itoa(n))
+			}
+		}
+		for _, f := range []string{"sort", "after"} {
+			if v, err := cmd.Flags().GetString(f); err == nil && v != "" {
+				params.Set(f, v)
+			}
+		}
 		client := &http.Client{Timeout: 5 * time.Second}
-		req, err := newHisterRequest("GET", "/search?q="+url.QueryEscape(qs), nil)
+		req, err := newHisterRequest("GET", "/search?"+params.Encode(), nil)
 		if err != nil {
 			exit(1, "Failed to create request: "+err.Error())
 		}
@@ -146,6 +161,9 @@ var searchCmd = &cobra.Command{
 		if err != nil {
 			exit(1, err.Error())
 		}
+		if resp.StatusCode != http.StatusOK {
+			exit(1, fmt.Sprintf("Search failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(body))))
+		}
 		var res *indexer.Results
 		err = json.Unmarshal(body, &res)
 		if err != nil {
@@ -154,6 +172,9 @@ var searchCmd = &cobra.Command{
 		for _, r := range res.Documents {
 			fmt.Printf("%s\n%s\n\n", r.Title, r.URL)
 		}
+		if res.HasMore {
+			fmt.Fprintln(os.Stderr, cliInfoStyle.Render(fmt.Sprintf("Showing %d-%d of about %d results. Use --after %s to get the next page.", res.Offset+1, res.Offset+len(res.Documents), res.Total, res.Next)))
+		}
 	},
 }
 
@@ -172,6 +193,42 @@ var indexCmd = &cobra.Command{
 	},
 }
 
+var indexDirCmd = &cobra.Command{
+	Use:   "index-dir PATH [PATH...]",
+	Short: "Index local files",
+	Long: `Index the Markdown, HTML, PDF, text and source code files of local directories - server should be stopped
+
+Only new and modified files are indexed on subsequent runs, deleted files are removed from the index.
+Hidden files and directories are skipped. Glob patterns match the file name or, if they contain
+a slash, the path relative to the indexed directory. ** matches any number of directories.
+
+Add the directories to the "directories" section of the config file with "watch: true"
+to keep them in sync while the server is running.`,
+	Args: cobra.MinimumNArgs(1),
+	PreRun: func(_ *cobra.Command, _ []string) {
+		initIndex()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		include, _ := cmd.Flags().GetStringSlice("include")
+		exclude, _ := cmd.Flags().GetStringSlice("exclude")
+		for _, p := range args {
+			dir, err := config.NewDirectory(p, include, exclude)
+			if err != nil {
+				exit(1, err.Error())
+			}
+			s, err := indexer.IndexDirectory(dir)
+			if err != nil {
+				exit(1, "Failed to index directory: "+err.Error())
+			}
+			fmt.Printf("%s %s: %d indexed, %d unchanged, %d deleted", cliSuccessStyle.Render("✓"), cliInfoStyle.Render(dir.Path), s.Indexed, s.Unchanged, s.Deleted)
+			if s.Failed > 0 {
+				fmt.Print(cliWarningStyle.Render(fmt.Sprintf(", %d failed", s.Failed)))
+			}
+			fmt.Println()
+		}
+	},
+}
+
 var deleteCmd = &cobra.Command{
 	Use:   "delete URL [URL...]",
 	Short: "Remove page from the index",
@@ -207,16 +264,29 @@ var deleteCmd = &cobra.Command{
 var reindexCmd = &cobra.Command{
 	Use:   "reindex",
 	Short: "Reindex",
-	Long:  `Recreate index - server should be stopped`,
-	PreRun: func(_ *cobra.Command, _ []string) {
-		initDB()
+	Long: `Recreate index - server should be stopped
+
+Use --online to rebuild the index of a running server without downtime.`,
+	PreRun: func(cmd *cobra.Command, _ []string) {
+		if online, _ := cmd.Flags().GetBool("online"); !online {
+			initDB()
+		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		skipSensitive := false
+		excludeSensitive := false
 		if b, err := cmd.Flags().GetBool("exclude-sensitive"); err == nil {
-			skipSensitive = b
+			excludeSensitive = b
+		}
+		workers, _ := cmd.Flags().GetInt("workers")
+		if online, _ := cmd.Flags().GetBool("online"); online {
+			setStrArg(cmd, "server-url", &cfg.Server.BaseURL)
+			onlineReindex(excludeSensitive, workers)
+			return
 		}
-		err := indexer.Reindex(cfg.IndexPath(), cfg.FullPath("tmp_index.db"), cfg.Rules, skipSensitive)
+		err := indexer.Reindex(cfg, &indexer.ReindexOptions{
+			SkipSensitiveChecks: excludeSensitive,
+			Workers:             workers,
+		})
 		if err != nil {
 			exit(1, err.Error())
 		}
@@ -226,6 +296,231 @@ var reindexCmd = &cobra.Command{
 	},
 }
 
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Find near-duplicate documents",
+	Long: `Report clusters of near-duplicate documents - server should be stopped
+
+The document with the most visits is kept when merging a cluster,
+visits of the other documents are moved to it.
+Documents indexed by older versions need a reindex to be detected.`,
+	PreRun: func(_ *cobra.Command, _ []string) {
+		initIndex()
+	},
+	Run: func(cmd *cobra.Command, _ []string) {
+		merge, _ := cmd.Flags().GetBool("merge")
+		clusters := indexer.FindDuplicates()
+		if len(clusters) == 0 {
+			fmt.Println("No duplicates found")
+			return
+		}
+		for n, c := range clusters {
+			fmt.Println(cliBoldStyle.Render(fmt.Sprintf("Cluster #%d", n+1)))
+			for j, d := range c {
+				mark := " "
+				if j == 0 {
+					mark = cliSuccessStyle.Render("*")
+				}
+				fmt.Printf(" %s %s %s\n", mark, cliInfoStyle.Render(d.URL), fmt.Sprintf("(%d visits)", d.Visits))
+			}
+		}
+		if !merge {
+			fmt.Printf("\n%d clusters found. Use --merge to keep only the marked documents\n", len(clusters))
+			return
+		}
+		if !yesNoPrompt(fmt.Sprintf("Merge %d clusters", len(clusters)), false) {
+			return
+		}
+		for _, c := range clusters {
+			if err := indexer.MergeDuplicates(c); err != nil {
+				exit(1, "Failed to merge duplicates: "+err.Error())
+			}
+		}
+		fmt.Println(cliSuccessStyle.Render("✓") + " Duplicates merged")
+	},
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Find sensitive content in the index",
+	Long: `Scan the indexed documents and their revisions against the current
+sensitive content patterns
+
+Offending documents can be deleted or redacted one by one, or all at once
+using --delete or --redact. The matched content is never printed.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setStrArg(cmd, "server-url", &cfg.Server.BaseURL)
+		patterns, _ := cmd.Flags().GetStringSlice("pattern")
+		del, _ := cmd.Flags().GetBool("delete")
+		redact, _ := cmd.Flags().GetBool("redact")
+		list, _ := cmd.Flags().GetBool("list")
+		if del && redact {
+			exit(1, "--delete and --redact are mutually exclusive")
+		}
+		client := &http.Client{Timeout: 5 * time.Minute}
+		q := url.Values{}
+		if len(patterns) > 0 {
+			q.Set("patterns", strings.Join(patterns, ","))
+		}
+		req, err := newHisterRequest("GET", "/audit?"+q.Encode(), nil)
+		if err != nil {
+			exit(1, "Failed to create request: "+err.Error())
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			exit(1, "Failed to send request to hister: "+err.Error())
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			exit(1, fmt.Sprintf("Failed to audit documents (%d): %s", resp.StatusCode, strings.TrimSpace(string(body))))
+		}
+		var res []*indexer.AuditResult
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			exit(1, "Failed to parse audit results: "+err.Error())
+		}
+		if len(res) == 0 {
+			fmt.Println(cliSuccessStyle.Render("✓") + " No sensitive content found")
+			return
+		}
+		fmt.Printf("%d documents contain sensitive content\n\n", len(res))
+		if list || del || redact {
+			for _, r := range res {
+				printAuditResult(r)
+			}
+		}
+		if list {
+			return
+		}
+		if del || redact {
+			action := "delete"
+			if redact {
+				action = "redact"
+			}
+			if !yesNoPrompt(fmt.Sprintf("%s %d documents", strings.ToUpper(action[:1])+action[1:], len(res)), false) {
+				return
+			}
+			for _, r := range res {
+				if err := fixAuditResult(r.URL, action, patterns); err != nil {
+					exit(1, err.Error())
+				}
+			}
+			fmt.Println(cliSuccessStyle.Render("✓") + " Done")
+			return
+		}
+		for _, r := range res {
+			printAuditResult(r)
+			switch choicePrompt("Skip, delete, redact or quit?", []string{"s", "d", "r", "q"}) {
+			case "d":
+				if err := fixAuditResult(r.URL, "delete", patterns); err != nil {
+					exit(1, err.Error())
+				}
+			case "r":
+				if err := fixAuditResult(r.URL, "redact", patterns); err != nil {
+					exit(1, err.Error())
+				}
+			case "q":
+				return
+			}
+		}
+	},
+}
+
+func printAuditResult(r *indexer.AuditResult) {
+	ps := make([]string, 0, len(r.Patterns))
+	for _, p := range r.Patterns {
+		ps = append(ps, fmt.Sprintf("%s (%dx)", p.Pattern, p.Count))
+	}
+	fmt.Println(cliInfoStyle.Render(r.URL), r.Title)
+	fmt.Println("  " + cliWarningStyle.Render(strings.Join(ps, ", ")))
+	if r.Revisions > 0 {
+		fmt.Printf("  found in %d stored revisions\n", r.Revisions)
+	}
+}
+
+func fixAuditResult(u, action string, patterns []string) error {
+	formData := url.Values{
+		"url":      {u},
+		"action":   {action},
+		"patterns": {strings.Join(patterns, ",")},
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	req, err := newHisterRequest("POST", "/audit", strings.NewReader(formData.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to hister: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to %s %s (%d): %s", action, u, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func onlineReindex(excludeSensitive bool, workers int) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	formData := url.Values{
+		"exclude_sensitive": {strconv.FormatBool(excludeSensitive)},
+		"workers":           {strconv.Itoa(workers)},
+	}
+	req, err := newHisterRequest("POST", "/reindex", strings.NewReader(formData.Encode()))
+	if err != nil {
+		exit(1, "Failed to create request: "+err.Error())
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		exit(1, "Failed to send request to hister: "+err.Error())
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		exit(1, err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		exit(1, fmt.Sprintf("Failed to start reindex (%d): %s", resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+	fmt.Println(cliInfoStyle.Render("Reindex started, searches are served from the current index until it finishes"))
+	for {
+		time.Sleep(time.Second)
+		req, err := newHisterRequest("GET", "/reindex", nil)
+		if err != nil {
+			exit(1, "Failed to create request: "+err.Error())
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			exit(1, "Failed to send request to hister: "+err.Error())
+		}
+		var s *indexer.ReindexStatus
+		err = json.NewDecoder(resp.Body).Decode(&s)
+		resp.Body.Close()
+		if err != nil {
+			exit(1, "Failed to parse reindex status: "+err.Error())
+		}
+		pct := 100
+		if s.Total > 0 {
+			pct = min(100, s.Processed*100/int(s.Total))
+		}
+		eta := (time.Duration(s.ETA) * time.Second).String()
+		fmt.Fprintf(os.Stderr, "\r\033[KReindexing: %d/%d documents (%d%%), %d skipped, %.1f docs/s, ETA %s", s.Processed, s.Total, pct, s.Skipped, s.DocsPerSec, eta)
+		if !s.Running {
+			fmt.Fprintln(os.Stderr)
+			if s.Error != "" {
+				exit(1, "Reindex failed: "+s.Error)
+			}
+			fmt.Println(cliSuccessStyle.Render("✓") + " Reindex finished")
+			return
+		}
+	}
+}
+
 func exit(errno int, msg string) {
 	if errno != 0 {
 		fmt.Println(cliErrorStyle.Render("Error!") + " " + msg)
@@ -244,17 +539,39 @@ func init() {
 	rootCmd.AddCommand(createConfigCmd)
 	rootCmd.AddCommand(listURLsCmd)
 	rootCmd.AddCommand(indexCmd)
+	rootCmd.AddCommand(indexDirCmd)
 	rootCmd.AddCommand(importCmd)
 	rootCmd.AddCommand(searchCmd)
 	rootCmd.AddCommand(reindexCmd)
 	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(dedupeCmd)
+	rootCmd.AddCommand(auditCmd)
 
 	dcfg := config.CreateDefaultConfig()
 	listenCmd.Flags().StringP("address", "a", dcfg.Server.Address, "Listen address")
 	indexCmd.Flags().StringP("server-url", "u", dcfg.Server.BaseURL, "hister server URL")
 
+	indexDirCmd.Flags().StringSliceP("include", "i", nil, "only index files matching the glob patterns")
+	indexDirCmd.Flags().StringSliceP("exclude", "e", nil, "skip files and directories matching the glob patterns")
+
+	searchCmd.Flags().IntP("limit", "n", 0, "maximum number of results (default 100)")
+	searchCmd.Flags().IntP("offset", "o", 0, "number of results to skip")
+	searchCmd.Flags().StringP("after", "a", "", "cursor of the page to get, printed after the results")
+	searchCmd.Flags().String("sort", "", "comma separated sort keys (relevance, added, title, url, domain, visits, newest, oldest, frecency), prefix with - or suffix with :asc/:desc to set direction")
+
+	dedupeCmd.Flags().BoolP("merge", "m", false, "merge duplicate clusters")
+
+	auditCmd.Flags().StringP("server-url", "u", dcfg.Server.BaseURL, "hister server URL")
+	auditCmd.Flags().StringSliceP("pattern", "p", nil, "only check the given sensitive content patterns")
+	auditCmd.Flags().Bool("list", false, "only list the offending documents")
+	auditCmd.Flags().Bool("delete", false, "delete every offending document")
+	auditCmd.Flags().Bool("redact", false, "redact the sensitive content of every offending document")
+
 	importCmd.Flags().IntP("min-visit", "m", 1, "only import URLs that were opened at least 'min-visit' times")
 
+	reindexCmd.Flags().IntP("workers", "w", 0, "number of documents processed in parallel (default: number of CPUs of the indexing host)")
+	reindexCmd.Flags().Bool("online", false, "rebuild the index of the running server in the background")
+	reindexCmd.Flags().StringP("server-url", "u", dcfg.Server.BaseURL, "hister server URL")
 	reindexCmd.Flags().BoolP("exclude-sensitive", "x", false, "don't add documents that contain sensitive content matched by config.SensitiveContentPatterns")
 
 	cobra.OnInitialize(initialize)
@@ -414,26 +731,26 @@ func yesNoPrompt(label string, def bool) bool {
 //		}
 //	}
 //}
-//
-//func choicePrompt(label string, choices []string) string {
-//	prompt := []byte(fmt.Sprintf("%s [%s,%s] ", label, strings.ToUpper(choices[0]), strings.Join(choices[1:], ",")))
-//
-//	r := bufio.NewReader(os.Stdin)
-//	var s string
-//
-//	for {
-//		os.Stderr.Write(prompt)
-//		s, _ = r.ReadString('\n')
-//		s = strings.TrimSpace(s)
-//		if s == "" {
-//			return choices[0]
-//		}
-//		s = strings.ToLower(s)
-//		if slices.Contains(choices, s) {
-//			return s
-//		}
-//	}
-//}
+
+func choicePrompt(label string, choices []string) string {
+	prompt := fmt.Appendf(nil, "%s [%s,%s] ", label, strings.ToUpper(choices[0]), strings.Join(choices[1:], ","))
+
+	r := bufio.NewReader(os.Stdin)
+	var s string
+
+	for {
+		os.Stderr.Write(prompt)
+		s, _ = r.ReadString('\n')
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return choices[0]
+		}
+		s = strings.ToLower(s)
+		if slices.Contains(choices, s) {
+			return s
+		}
+	}
+}
 
 func indexURL(u string) error {
 	client := &http.Client{
@@ -458,7 +775,8 @@ func indexURL(u string) error {
 		return fmt.Errorf("invalid response code: %d", r.StatusCode)
 	}
 	contentType := r.Header.Get("Content-type")
-	if !strings.Contains(contentType, "html") {
+	isHTML := strings.Contains(contentType, "html")
+	if !isHTML && !indexer.IsSupportedContentType(contentType, u) {
 		return errors.New("invalid content type: " + contentType)
 	}
 	buf := bytes.NewBuffer(nil)
@@ -468,8 +786,13 @@ func indexURL(u string) error {
 	}
 
 	d := &indexer.Document{
-		URL:  u,
-		HTML: buf.String(),
+		URL: u,
+	}
+	if isHTML {
+		d.HTML = buf.String()
+	} else {
+		d.ContentType = contentType
+		d.Data = base64.StdEncoding.EncodeToString(buf.Bytes())
 	}
 	if err := d.Process(); err != nil {
 		return errors.New(`failed to process document: ` + err.Error())
@@ -495,6 +818,14 @@ func indexURL(u string) error {
 		return errors.New(`failed to send page to hister: ` + err.Error())
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnprocessableEntity {
+		var ar struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&ar); err == nil && ar.Error != "" {
+			return errors.New(`page rejected by hister: ` + ar.Error)
+		}
+	}
 	if resp.StatusCode != http.StatusCreated {
 		return fmt.Errorf("failed to send page to hister: Invalid status code (%d)", resp.StatusCode)
 	}
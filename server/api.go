@@ -63,6 +63,80 @@ func init() {
 			CSRFRequired: false,
 			Handler:      serveSearch,
 			Description:  "Search websocket endpoint",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "q",
+					Type:        "string",
+					Required:    false,
+					Description: "Search query - returns JSON results instead of upgrading to websocket",
+				},
+				&EndpointArg{
+					Name:        "date_from",
+					Type:        "string",
+					Required:    false,
+					Description: "Only return documents added after this date (YYYY-MM-DD)",
+				},
+				&EndpointArg{
+					Name:        "date_to",
+					Type:        "string",
+					Required:    false,
+					Description: "Only return documents added before this date (YYYY-MM-DD)",
+				},
+				&EndpointArg{
+					Name:        "sort",
+					Type:        "string",
+					Required:    false,
+					Description: "Comma separated sort keys: relevance, added, title, url, domain, visits, newest, oldest or frecency. Use -key or key:asc/key:desc to set the direction",
+				},
+				&EndpointArg{
+					Name:        "limit",
+					Type:        "int",
+					Required:    false,
+					Description: "Maximum number of returned documents (default 100)",
+				},
+				&EndpointArg{
+					Name:        "offset",
+					Type:        "int",
+					Required:    false,
+					Description: "Number of documents to skip",
+				},
+				&EndpointArg{
+					Name:        "facets",
+					Type:        "bool",
+					Required:    false,
+					Description: "Return domain and date facets",
+				},
+				&EndpointArg{
+					Name:        "highlight",
+					Type:        "string",
+					Required:    false,
+					Description: "Highlight style of the matches: HTML, text (ANSI), tui or structured (plain text with match offsets)",
+				},
+				&EndpointArg{
+					Name:        "fragments",
+					Type:        "int",
+					Required:    false,
+					Description: "Number of highlighted text fragments per document (default 1, max 10)",
+				},
+				&EndpointArg{
+					Name:        "fragment_size",
+					Type:        "int",
+					Required:    false,
+					Description: "Size of the highlighted fragments in characters (default 200)",
+				},
+				&EndpointArg{
+					Name:        "no_correction",
+					Type:        "bool",
+					Required:    false,
+					Description: "Disable the spelling correction of queries having few or no results",
+				},
+				&EndpointArg{
+					Name:        "facet_interval",
+					Type:        "string",
+					Required:    false,
+					Description: "Date histogram interval: day, week or month (default)",
+				},
+			},
 		},
 		&Endpoint{
 			Name:         "Add",
@@ -96,6 +170,144 @@ func init() {
 				},
 			},
 		},
+		&Endpoint{
+			Name:         "Document backlinks",
+			Path:         "/document/backlinks",
+			Method:       GET,
+			CSRFRequired: false,
+			Handler:      serveBacklinks,
+			Description:  "List the indexed pages linking to a document, most recently visited first",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "url",
+					Type:        "string",
+					Required:    true,
+					Description: "URL of the document",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Document revisions",
+			Path:         "/document/revisions",
+			Method:       GET,
+			CSRFRequired: false,
+			Handler:      serveRevisions,
+			Description:  "List the stored content revisions of a document",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "url",
+					Type:        "string",
+					Required:    true,
+					Description: "URL of the document",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Document diff",
+			Path:         "/document/diff",
+			Method:       GET,
+			CSRFRequired: false,
+			Handler:      serveRevisionDiff,
+			Description:  "Unified diff between two revisions of a document",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "url",
+					Type:        "string",
+					Required:    true,
+					Description: "URL of the document",
+				},
+				&EndpointArg{
+					Name:        "from",
+					Type:        "int",
+					Required:    true,
+					Description: "ID of the older revision",
+				},
+				&EndpointArg{
+					Name:        "to",
+					Type:        "int",
+					Required:    false,
+					Description: "ID of the newer revision (defaults to the latest revision)",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Document tags",
+			Path:         "/document/tags",
+			Method:       POST,
+			CSRFRequired: true,
+			Handler:      serveDocumentTags,
+			Description:  "Add or remove tags of a document",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "url",
+					Type:        "string",
+					Required:    true,
+					Description: "URL of the document",
+				},
+				&EndpointArg{
+					Name:        "add",
+					Type:        "string",
+					Required:    false,
+					Description: "Comma separated list of tags to add",
+				},
+				&EndpointArg{
+					Name:        "remove",
+					Type:        "string",
+					Required:    false,
+					Description: "Comma separated list of tags to remove",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Document note",
+			Path:         "/document/note",
+			Method:       POST,
+			CSRFRequired: true,
+			Handler:      serveDocumentNote,
+			Description:  "Set the note of a document",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "url",
+					Type:        "string",
+					Required:    true,
+					Description: "URL of the document",
+				},
+				&EndpointArg{
+					Name:        "note",
+					Type:        "string",
+					Required:    false,
+					Description: "Note text, empty value removes the note",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Revisions",
+			Path:         "/revisions",
+			Method:       GET,
+			CSRFRequired: false,
+			Handler:      serveRevisionsPage,
+			Description:  "Revision history and diff view of a document",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "url",
+					Type:        "string",
+					Required:    true,
+					Description: "URL of the document",
+				},
+				&EndpointArg{
+					Name:        "from",
+					Type:        "int",
+					Required:    false,
+					Description: "ID of the older revision (defaults to the previous revision)",
+				},
+				&EndpointArg{
+					Name:        "to",
+					Type:        "int",
+					Required:    false,
+					Description: "ID of the newer revision (defaults to the latest revision)",
+				},
+			},
+		},
 		&Endpoint{
 			Name:         "Rules",
 			Path:         "/rules",
@@ -144,6 +356,80 @@ func init() {
 			Handler:      serveDeleteDocument,
 			Description:  "Delete document endpoint",
 		},
+		&Endpoint{
+			Name:         "Reindex status",
+			Path:         "/reindex",
+			Method:       GET,
+			CSRFRequired: false,
+			Handler:      serveReindexStatus,
+			Description:  "Progress of the online reindex job",
+		},
+		&Endpoint{
+			Name:         "Reindex",
+			Path:         "/reindex",
+			Method:       POST,
+			CSRFRequired: true,
+			Handler:      serveStartReindex,
+			Description:  "Start rebuilding the index in the background",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "exclude_sensitive",
+					Type:        "bool",
+					Required:    false,
+					Description: "Drop documents that contain sensitive content",
+				},
+				&EndpointArg{
+					Name:        "workers",
+					Type:        "int",
+					Required:    false,
+					Description: "Number of documents processed in parallel (default: number of CPUs)",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Audit",
+			Path:         "/audit",
+			Method:       GET,
+			CSRFRequired: false,
+			Handler:      serveAudit,
+			Description:  "List stored documents matching the sensitive content patterns",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "patterns",
+					Type:        "string",
+					Required:    false,
+					Description: "Comma separated list of pattern names to check (default: all patterns)",
+				},
+			},
+		},
+		&Endpoint{
+			Name:         "Audit fix",
+			Path:         "/audit",
+			Method:       POST,
+			CSRFRequired: true,
+			Handler:      serveAuditFix,
+			Description:  "Delete or redact a document found by the audit",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "url",
+					Type:        "string",
+					Required:    true,
+					Description: "URL of the document",
+				},
+				&EndpointArg{
+					Name:        "action",
+					Type:        "string",
+					Required:    true,
+					Description: "'delete' or 'redact'",
+				},
+				&EndpointArg{
+					Name:        "patterns",
+					Type:        "string",
+					Required:    false,
+					Description: "Comma separated list of pattern names to redact (default: all patterns)",
+				},
+			},
+		},
 		&Endpoint{
 			Name:         "Delete alias",
 			Path:         "/delete_alias",
@@ -184,6 +470,34 @@ func init() {
 			Handler:      serveOpensearch,
 			Description:  "OpenSearch XML descriptor",
 		},
+		&Endpoint{
+			Name:         "Suggestions",
+			Path:         "/suggestions",
+			Method:       GET,
+			CSRFRequired: false,
+			Handler:      serveSuggestions,
+			Description:  "Query completions from past queries, document titles and index terms, in OpenSearch suggestions format by default",
+			Args: []*EndpointArg{
+				&EndpointArg{
+					Name:        "q",
+					Type:        "string",
+					Required:    true,
+					Description: "Partially typed query",
+				},
+				&EndpointArg{
+					Name:        "format",
+					Type:        "string",
+					Required:    false,
+					Description: "Response format: opensearch (default) or json for the detailed list of suggestions",
+				},
+				&EndpointArg{
+					Name:        "limit",
+					Type:        "int",
+					Required:    false,
+					Description: "Maximum number of suggestions (default 10)",
+				},
+			},
+		},
 		&Endpoint{
 			Name:         "Favicon",
 			Path:         "/favicon.ico",
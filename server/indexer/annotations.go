@@ -0,0 +1,103 @@
+package indexer
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2/search"
+)
+
+var ErrDocumentNotFound = errors.New("document not found")
+
+// annotationMu serializes the read-modify-write cycles of annotation updates
+// and the indexing of added documents keeping the annotations of their indexed version
+var annotationMu sync.Mutex
+
+// annotationFields are the stored fields read to merge the annotations of added documents
+var annotationFields = []string{"url", "tags", "note", "visits", "first_seen", "last_seen"}
+
+// normalizeTag converts a tag to lowercase and replaces whitespace with dashes
+func normalizeTag(t string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(t), unicode.IsSpace), "-")
+}
+
+func normalizeTags(tags []string) []string {
+	ret := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if t = normalizeTag(t); t != "" && !slices.Contains(ret, t) {
+			ret = append(ret, t)
+		}
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+func readTags(h *search.DocumentMatch) []string {
+	return readStringList(h, "tags")
+}
+
+// readStringList returns the values of a multi-valued stored field
+func readStringList(h *search.DocumentMatch, field string) []string {
+	switch v := h.Fields[field].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		ret := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				ret = append(ret, s)
+			}
+		}
+		return ret
+	}
+	return nil
+}
+
+func updateAnnotations(u string, fn func(*Document)) (*Document, error) {
+	annotationMu.Lock()
+	defer annotationMu.Unlock()
+	d := GetByURL(u)
+	if d == nil {
+		return nil, ErrDocumentNotFound
+	}
+	fn(d)
+	d.Tags = normalizeTags(d.Tags)
+	if err := i.index(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// UpdateTags adds and removes tags of an indexed document.
+func UpdateTags(u string, add, remove []string) (*Document, error) {
+	remove = normalizeTags(remove)
+	return updateAnnotations(u, func(d *Document) {
+		d.Tags = slices.DeleteFunc(normalizeTags(append(d.Tags, add...)), func(t string) bool {
+			return slices.Contains(remove, t)
+		})
+	})
+}
+
+// SetNote replaces the note of an indexed document.
+func SetNote(u, note string) (*Document, error) {
+	return updateAnnotations(u, func(d *Document) {
+		d.Note = strings.TrimSpace(note)
+	})
+}
+
+// copyAnnotations keeps the user provided annotations of the indexed version of a document
+func (d *Document) copyAnnotations(e *Document) {
+	if !d.hasOwnFields {
+		d.ownTags = slices.Clone(d.Tags)
+		d.ownNote = d.Note
+		d.hasOwnFields = true
+	}
+	d.Tags = normalizeTags(append(slices.Clone(d.ownTags), e.Tags...))
+	d.Note = d.ownNote
+	if d.Note == "" {
+		d.Note = e.Note
+	}
+}
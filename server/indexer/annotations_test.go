@@ -0,0 +1,60 @@
+package indexer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnnotationsSurviveUpdates(t *testing.T) {
+	cfg := initTestIndex(t)
+	const u = "https://example.com/annotated"
+	addTestDocument(t, u, "Annotated", "annotated document")
+	addTestDocument(t, "https://example.com/other", "Other", "annotated document")
+	if _, err := UpdateTags(u, []string{"Read Later", "go", "go"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetNote(u, " my note "); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UpdateTags("https://example.com/missing", []string{"go"}, nil); err != ErrDocumentNotFound {
+		t.Errorf("UpdateTags() of a missing document error = %v, want ErrDocumentNotFound", err)
+	}
+
+	checkAnnotations := func(step string) {
+		t.Helper()
+		d := GetByURL(u)
+		if d == nil {
+			t.Fatalf("%s: document is missing", step)
+		}
+		if want := []string{"go", "read-later"}; !slices.Equal(d.Tags, want) {
+			t.Errorf("%s: tags = %q, want %q", step, d.Tags, want)
+		}
+		if d.Note != "my note" {
+			t.Errorf("%s: note = %q, want %q", step, d.Note, "my note")
+		}
+		r, err := Search(cfg, &Query{Text: "tag:read-later"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r.Documents) != 1 || r.Documents[0].URL != u {
+			t.Errorf("%s: tag search returned %d documents, want %s", step, len(r.Documents), u)
+		}
+	}
+	checkAnnotations("update")
+
+	addTestDocument(t, u, "Annotated", "annotated document")
+	checkAnnotations("unchanged resubmission")
+
+	addTestDocument(t, u, "Annotated", "changed annotated document")
+	checkAnnotations("changed resubmission")
+
+	reindexTestIndex(t, cfg, &ReindexOptions{})
+	checkAnnotations("reindex")
+
+	if _, err := UpdateTags(u, nil, []string{"READ LATER"}); err != nil {
+		t.Fatal(err)
+	}
+	if d := GetByURL(u); !slices.Equal(d.Tags, []string{"go"}) {
+		t.Errorf("tags after removal = %q, want [go]", d.Tags)
+	}
+}
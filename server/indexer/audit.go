@@ -0,0 +1,219 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+
+	"github.com/asciimoo/hister/server/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+// AuditResult lists the sensitive content patterns found in a stored document and its revisions
+type AuditResult struct {
+	URL       string            `json:"url"`
+	Title     string            `json:"title"`
+	Patterns  []*SensitiveMatch `json:"patterns"`
+	Revisions int               `json:"revisions"`
+}
+
+var ErrUnknownPattern = errors.New("unknown sensitive content pattern")
+
+// auditPatterns returns the configured sensitive patterns with the given names or all of them if names is empty
+func auditPatterns(names []string) ([]*sensitivePattern, error) {
+	if len(names) == 0 {
+		return sensitivePatterns, nil
+	}
+	ps := make([]*sensitivePattern, 0, len(names))
+	for _, n := range names {
+		idx := slices.IndexFunc(sensitivePatterns, func(p *sensitivePattern) bool {
+			return p.name == n
+		})
+		if idx == -1 {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPattern, n)
+		}
+		ps = append(ps, sensitivePatterns[idx])
+	}
+	return ps, nil
+}
+
+// findSensitive returns the patterns matching any of the texts.
+// Patterns allowed on the given host are ignored.
+func findSensitive(ps []*sensitivePattern, host string, texts ...string) []*SensitiveMatch {
+	var ms []*SensitiveMatch
+	for _, p := range ps {
+		if p.action.AllowedOn(host) {
+			continue
+		}
+		// the same content is stored in multiple forms, count the most matches of a single form
+		n := 0
+		for _, t := range texts {
+			n = max(n, len(p.re.FindAllStringIndex(t, -1)))
+		}
+		if n > 0 {
+			ms = append(ms, &SensitiveMatch{
+				Pattern: p.name,
+				Action:  p.action.Action,
+				Count:   n,
+			})
+		}
+	}
+	return ms
+}
+
+func redactSensitive(ps []*sensitivePattern, s string) string {
+	for _, p := range ps {
+		s = p.re.ReplaceAllLiteralString(s, redactedPlaceholder(p.name))
+	}
+	return s
+}
+
+// pdfFields returns the text fields of the stored raw PDF content of the document
+func (d *Document) pdfFields() []string {
+	if d.Data == "" || !d.IsPDF() {
+		return nil
+	}
+	p := &Document{URL: d.URL, ContentType: d.ContentType, Data: d.Data}
+	if err := (&pdfExtractor{}).Extract(p); err != nil {
+		return nil
+	}
+	return []string{p.Title, p.Text, p.Author}
+}
+
+func urlHost(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return pu.Hostname()
+}
+
+// Audit scans the indexed documents and the stored revisions against the
+// current sensitive content patterns. The results don't contain the matched content.
+func Audit(patterns []string) ([]*AuditResult, error) {
+	ps, err := auditPatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]*AuditResult)
+	err = streamDocuments(i.search, func(d *Document) error {
+		if ms := findSensitive(ps, d.Domain, append(d.textFields(), d.pdfFields()...)...); len(ms) > 0 {
+			results[d.URL] = &AuditResult{
+				URL:      d.URL,
+				Title:    redactSensitive(ps, d.Title),
+				Patterns: ms,
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if model.DB != nil {
+		err = model.IterateRevisions(func(r *model.Revision) error {
+			ms := findSensitive(ps, urlHost(r.URL), r.Title, r.Text, r.HTML)
+			if len(ms) == 0 {
+				return nil
+			}
+			res, ok := results[r.URL]
+			if !ok {
+				res = &AuditResult{
+					URL:   r.URL,
+					Title: redactSensitive(ps, r.Title),
+				}
+				results[r.URL] = res
+			}
+			res.Revisions++
+			for _, m := range ms {
+				idx := slices.IndexFunc(res.Patterns, func(e *SensitiveMatch) bool {
+					return e.Pattern == m.Pattern
+				})
+				if idx == -1 {
+					res.Patterns = append(res.Patterns, m)
+				} else {
+					res.Patterns[idx].Count += m.Count
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret := make([]*AuditResult, 0, len(results))
+	for _, r := range results {
+		sort.Slice(r.Patterns, func(a, b int) bool {
+			return r.Patterns[a].Pattern < r.Patterns[b].Pattern
+		})
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(a, b int) bool {
+		return ret[a].URL < ret[b].URL
+	})
+	return ret, nil
+}
+
+// Redact replaces the matches of the given sensitive content patterns
+// in the indexed document and in the stored revisions of a URL.
+// All patterns are applied if patterns is empty.
+func Redact(u string, patterns []string) error {
+	ps, err := auditPatterns(patterns)
+	if err != nil {
+		return err
+	}
+	host := urlHost(u)
+	ps = slices.DeleteFunc(slices.Clone(ps), func(p *sensitivePattern) bool {
+		return p.action.AllowedOn(host)
+	})
+	annotationMu.Lock()
+	defer annotationMu.Unlock()
+	found := false
+	if d := GetByURL(u); d != nil {
+		found = true
+		u = d.URL
+		pdf := d.pdfFields()
+		if len(findSensitive(ps, host, append(d.textFields(), pdf...)...)) > 0 {
+			d.replaceTextFields(func(s string) string {
+				return redactSensitive(ps, s)
+			})
+			if len(findSensitive(ps, host, pdf...)) > 0 {
+				// the matches can't be replaced in the PDF file, only its extracted content is kept
+				d.Data = ""
+			}
+			d.Simhash = simhash(d.Text)
+			if err := i.index(d); err != nil {
+				return err
+			}
+			log.Debug().Str("URL", u).Msg("Sensitive content redacted")
+		}
+	}
+	if model.DB == nil {
+		if !found {
+			return ErrDocumentNotFound
+		}
+		return nil
+	}
+	rs, err := model.GetRevisionContents(u)
+	if err != nil {
+		return err
+	}
+	if !found && len(rs) == 0 {
+		return ErrDocumentNotFound
+	}
+	for _, r := range rs {
+		if len(findSensitive(ps, host, r.Title, r.Text, r.HTML)) == 0 {
+			continue
+		}
+		r.Title = redactSensitive(ps, r.Title)
+		r.Text = redactSensitive(ps, r.Text)
+		r.HTML = redactSensitive(ps, r.HTML)
+		r.Hash = (&Document{Title: r.Title, Text: r.Text}).contentHash()
+		if err := model.UpdateRevision(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
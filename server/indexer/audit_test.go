@@ -0,0 +1,96 @@
+package indexer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/asciimoo/hister/server/model"
+)
+
+func TestAuditAndRedact(t *testing.T) {
+	cfg := initTestIndex(t)
+	const (
+		u      = "https://example.com/leaky"
+		pdfURL = "https://example.com/report.pdf"
+	)
+	// the documents are added before the patterns are configured
+	addTestDocument(t, u, "Leaky SECRET-9", "key SECRET-1")
+	addTestDocument(t, u, "Leaky SECRET-9", "keys SECRET-2 and SECRET-3")
+	addTestDocument(t, "https://example.com/clean", "Clean", "nothing to see")
+	pdf := &Document{
+		URL:         pdfURL,
+		ContentType: PDFContentType,
+		Data:        base64.StdEncoding.EncodeToString(testPDF("Report", "key SECRET-7")),
+	}
+	if err := Add(pdf); err != nil {
+		t.Fatal(err)
+	}
+	configureTestSensitivePatterns(cfg)
+
+	rs, err := Audit(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 2 || rs[0].URL != u || rs[1].URL != pdfURL {
+		t.Fatalf("audit results = %+v, want %s and %s", rs, u, pdfURL)
+	}
+	if rs[0].Revisions != 2 {
+		t.Errorf("matching revisions = %d, want 2", rs[0].Revisions)
+	}
+	if rs[0].Title != "Leaky [REDACTED:secret]" {
+		t.Errorf("title = %q, want the redacted title", rs[0].Title)
+	}
+	for _, r := range rs {
+		if len(r.Patterns) != 1 || r.Patterns[0].Pattern != "secret" || r.Patterns[0].Count == 0 {
+			t.Errorf("%s patterns = %+v, want secret", r.URL, r.Patterns)
+		}
+	}
+	if b, err := json.Marshal(rs); err != nil || strings.Contains(string(b), "SECRET-") {
+		t.Errorf("audit results contain the matched content: %s, %v", b, err)
+	}
+	if rs, err := Audit([]string{"token"}); err != nil || len(rs) != 0 {
+		t.Errorf("Audit(token) = %+v, %v, want no results", rs, err)
+	}
+	if _, err := Audit([]string{"unknown"}); !errors.Is(err, ErrUnknownPattern) {
+		t.Errorf("Audit(unknown) error = %v, want ErrUnknownPattern", err)
+	}
+
+	for _, v := range []string{u, pdfURL} {
+		if err := Redact(v, nil); err != nil {
+			t.Fatal(err)
+		}
+		d := GetByURL(v)
+		if d == nil {
+			t.Fatalf("%s is missing after redaction", v)
+		}
+		for _, f := range d.textFields() {
+			if strings.Contains(f, "SECRET-") {
+				t.Errorf("%s field %q isn't redacted", v, f)
+			}
+		}
+		revs, err := model.GetRevisionContents(v)
+		if err != nil || len(revs) == 0 {
+			t.Fatalf("%s revisions = %d, %v", v, len(revs), err)
+		}
+		for _, r := range revs {
+			if strings.Contains(r.Title+r.Text+r.HTML, "SECRET-") {
+				t.Errorf("%s revision %d isn't redacted", v, r.ID)
+			}
+			if h := (&Document{Title: r.Title, Text: r.Text}).contentHash(); r.Hash != h {
+				t.Errorf("%s revision %d hash = %s, want %s", v, r.ID, r.Hash, h)
+			}
+		}
+	}
+	if d := GetByURL(pdfURL); d.Data != "" || !strings.Contains(d.Text, "[REDACTED:secret]") {
+		t.Errorf("redacted PDF data = %d bytes, text = %q, want only the redacted text", len(d.Data), d.Text)
+	}
+	if rs, err := Audit(nil); err != nil || len(rs) != 0 {
+		t.Errorf("audit results after redaction = %+v, %v, want none", rs, err)
+	}
+	if err := Redact("https://example.com/missing", nil); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Redact() of a missing document error = %v, want ErrDocumentNotFound", err)
+	}
+}
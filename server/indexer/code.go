@@ -0,0 +1,125 @@
+package indexer
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxCodeLength is the maximum size of the code stored per document
+const maxCodeLength = 128 << 10
+
+// codeTokenRe splits code to identifiers, keeping dotted names,
+// dashed words and command line flags as single tokens
+const codeTokenRe = `-{0,2}[\p{L}\p{N}_$@]+(?:[.\-:]+[\p{L}\p{N}_$@]+)*`
+
+// extractCode collects the content of the code blocks and inline code elements of HTML documents
+func (d *Document) extractCode() {
+	if d.HTML == "" {
+		return
+	}
+	doc, err := parseDocument(d)
+	if err != nil {
+		return
+	}
+	var blocks []string
+	for _, n := range selectAll(doc, "body pre, body code") {
+		if insideCode(n) {
+			continue
+		}
+		blocks = append(blocks, codeText(n))
+	}
+	d.setCode(blocks)
+}
+
+// setCode stores the non-empty code blocks separated by empty lines
+func (d *Document) setCode(blocks []string) {
+	var sb strings.Builder
+	for _, b := range blocks {
+		b = strings.Trim(b, "\n")
+		if strings.TrimSpace(b) == "" {
+			continue
+		}
+		sep := ""
+		if sb.Len() > 0 {
+			sep = "\n\n"
+		}
+		if sb.Len()+len(sep)+len(b) > maxCodeLength {
+			// truncate at the last complete line
+			b = b[:max(0, maxCodeLength-sb.Len()-len(sep))]
+			if n := strings.LastIndexByte(b, '\n'); n > 0 {
+				sb.WriteString(sep)
+				sb.WriteString(b[:n])
+			}
+			break
+		}
+		sb.WriteString(sep)
+		sb.WriteString(b)
+	}
+	d.Code = sb.String()
+}
+
+// insideCode reports whether the node is part of an other code element
+func insideCode(n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.DataAtom == atom.Pre || p.DataAtom == atom.Code {
+			return true
+		}
+	}
+	return false
+}
+
+// codeText returns the text of a node keeping its whitespace
+func codeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			sb.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Button, atom.Template:
+				return
+			case atom.Br:
+				sb.WriteString("\n")
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// markdownCode returns the fenced code blocks and the code spans of a Markdown document
+func markdownCode(s string) []string {
+	var blocks []string
+	var block []string
+	inCode := false
+	for _, l := range strings.Split(s, "\n") {
+		if mdFenceRe.MatchString(l) {
+			if inCode {
+				blocks = append(blocks, strings.Join(block, "\n"))
+				block = nil
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			block = append(block, l)
+			continue
+		}
+		for _, m := range mdCodeSpanRe.FindAllStringSubmatch(l, -1) {
+			blocks = append(blocks, m[1])
+		}
+	}
+	if inCode {
+		blocks = append(blocks, strings.Join(block, "\n"))
+	}
+	return blocks
+}
@@ -0,0 +1,76 @@
+package indexer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetCode(t *testing.T) {
+	line := strings.Repeat("x", 99) + "\n"
+	long := strings.Repeat(line, maxCodeLength/len(line)+1)
+	tests := []struct {
+		name   string
+		blocks []string
+		want   string
+	}{
+		{
+			name:   "empty",
+			blocks: nil,
+			want:   "",
+		},
+		{
+			name:   "blank blocks",
+			blocks: []string{"", "  \n ", "\n"},
+			want:   "",
+		},
+		{
+			name:   "separated blocks",
+			blocks: []string{"\na := 1\n", "", "b()"},
+			want:   "a := 1\n\nb()",
+		},
+		{
+			name:   "first block at the limit",
+			blocks: []string{strings.Repeat("a", maxCodeLength)},
+			want:   strings.Repeat("a", maxCodeLength),
+		},
+		{
+			name:   "second block after a block of the limit minus one byte",
+			blocks: []string{strings.Repeat("a", maxCodeLength-1), "b\nc"},
+			want:   strings.Repeat("a", maxCodeLength-1),
+		},
+		{
+			name:   "second block after a block of the limit minus two bytes",
+			blocks: []string{strings.Repeat("a", maxCodeLength-2), "b"},
+			want:   strings.Repeat("a", maxCodeLength-2),
+		},
+		{
+			name:   "truncated at the last complete line",
+			blocks: []string{"a", long},
+			want:   "a\n\n" + strings.TrimSuffix(strings.Repeat(line, (maxCodeLength-3)/len(line)), "\n"),
+		},
+		{
+			name:   "too long single line",
+			blocks: []string{"a", strings.Repeat("b", maxCodeLength)},
+			want:   "a",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Document{}
+			d.setCode(tc.blocks)
+			if d.Code != tc.want {
+				t.Errorf("setCode() = %q (%d bytes), want %q (%d bytes)", shorten(d.Code), len(d.Code), shorten(tc.want), len(tc.want))
+			}
+			if len(d.Code) > maxCodeLength {
+				t.Errorf("setCode() stored %d bytes, limit is %d", len(d.Code), maxCodeLength)
+			}
+		})
+	}
+}
+
+func shorten(s string) string {
+	if len(s) > 40 {
+		return s[:20] + "..." + s[len(s)-20:]
+	}
+	return s
+}
@@ -0,0 +1,65 @@
+package indexer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/blevesearch/bleve/v2/search"
+)
+
+// cursor is the position of a result page. It is passed to clients as an opaque string.
+type cursor struct {
+	// After holds the sort values of the last hit of the previous page
+	After [][]byte `json:"a,omitempty"`
+	// Offset is the number of ranked hits before the page
+	Offset int `json:"o"`
+}
+
+func (c *cursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseCursor(s string) (*cursor, error) {
+	c := &cursor{}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, c)
+	}
+	if err != nil || c.Offset < 0 {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidQuery)
+	}
+	return c, nil
+}
+
+// searchAfter returns the SearchAfter values of the cursor
+func (c *cursor) searchAfter() []string {
+	if len(c.After) == 0 {
+		return nil
+	}
+	after := make([]string, len(c.After))
+	for j, v := range c.After {
+		after[j] = string(v)
+	}
+	return after
+}
+
+// cursorAfter returns the cursor pointing after the hit.
+// Sort values are stored raw, as bleve expects them in SearchAfter,
+// except the scores which aren't part of the sort values of the hits.
+func cursorAfter(order search.SortOrder, h *search.DocumentMatch, offset int) *cursor {
+	c := &cursor{
+		After:  make([][]byte, len(order)),
+		Offset: offset,
+	}
+	for j, s := range order {
+		if s.RequiresScoring() {
+			c.After[j] = []byte(strconv.FormatFloat(h.Score, 'g', -1, 64))
+		} else {
+			c.After[j] = []byte(h.Sort[j])
+		}
+	}
+	return c
+}
@@ -0,0 +1,54 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSearchCursorPaging(t *testing.T) {
+	cfg := initTestIndex(t)
+	const docs = 23
+	for j := range docs {
+		addTestDocument(t, fmt.Sprintf("https://example.com/%d", j), fmt.Sprintf("Page %d", j), fmt.Sprintf("paging test%d", j))
+	}
+	for _, sort := range []string{"", "title", "-added", "frecency"} {
+		t.Run(sort, func(t *testing.T) {
+			seen := make(map[string]int)
+			q := &Query{Text: "paging", Sort: sort, Limit: 5}
+			for pages := 0; ; pages++ {
+				if pages > docs {
+					t.Fatal("too many pages")
+				}
+				r, err := Search(cfg, q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, d := range r.Documents {
+					seen[d.URL]++
+				}
+				if !r.HasMore {
+					if r.Next != "" {
+						t.Errorf("next = %q on the last page", r.Next)
+					}
+					break
+				}
+				if len(r.Documents) == 0 {
+					t.Fatal("empty page with more results")
+				}
+				q = &Query{Text: "paging", Sort: sort, Limit: 5, After: r.Next}
+			}
+			if len(seen) != docs {
+				t.Errorf("got %d documents, want %d", len(seen), docs)
+			}
+			for u, n := range seen {
+				if n != 1 {
+					t.Errorf("%s returned %d times", u, n)
+				}
+			}
+		})
+	}
+	if _, err := Search(cfg, &Query{Text: "paging", After: "invalid"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Search() with invalid cursor error = %v, want ErrInvalidQuery", err)
+	}
+}
@@ -0,0 +1,212 @@
+package indexer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/asciimoo/hister/server/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// simhashShingleSize is the number of consecutive words hashed together
+	simhashShingleSize = 3
+	// simhashMinWords is the minimum number of words required to calculate a reliable fingerprint
+	simhashMinWords = 20
+	// duplicateDistance is the maximum number of differing fingerprint bits of near-duplicates
+	duplicateDistance = 3
+	// collapseWindow is the number of ranked hits fetched after the result page to collapse them into it
+	collapseWindow = 100
+)
+
+// simhash calculates a 64 bit locality sensitive fingerprint of a text.
+// Returns an empty string if the text is too short.
+func simhash(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) < simhashMinWords {
+		return ""
+	}
+	var v [64]int
+	h := fnv.New64a()
+	for j := 0; j+simhashShingleSize <= len(words); j++ {
+		h.Reset()
+		h.Write([]byte(strings.Join(words[j:j+simhashShingleSize], " ")))
+		s := h.Sum64()
+		for b := range 64 {
+			if s&(1<<b) != 0 {
+				v[b]++
+			} else {
+				v[b]--
+			}
+		}
+	}
+	var fp uint64
+	for b := range 64 {
+		if v[b] > 0 {
+			fp |= 1 << b
+		}
+	}
+	return fmt.Sprintf("%016x", fp)
+}
+
+func parseSimhash(s string) (uint64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	fp, err := strconv.ParseUint(s, 16, 64)
+	return fp, err == nil
+}
+
+func isNearDuplicate(a, b uint64) bool {
+	return bits.OnesCount64(a^b) <= duplicateDistance
+}
+
+// collapsePage merges near-duplicate documents into their first, highest ranked occurrence
+// and returns at most `limit` documents. It also returns the number of documents
+// consumed by the page, which is the position of the next page, and the number of
+// collapsed documents of the whole list.
+func collapsePage(docs []*Document, limit int) ([]*Document, int, int) {
+	var page []*Document
+	var leaders []*Document
+	var fps []uint64
+	next := -1
+	collapsed := 0
+out:
+	for j, d := range docs {
+		fp, ok := parseSimhash(d.Simhash)
+		if ok {
+			for k, o := range leaders {
+				if isNearDuplicate(fp, fps[k]) {
+					o.Duplicates = append(o.Duplicates, d.URL)
+					collapsed++
+					continue out
+				}
+			}
+		}
+		switch {
+		case len(page) < limit:
+			page = append(page, d)
+		case next == -1:
+			next = j
+		}
+		if ok {
+			leaders = append(leaders, d)
+			fps = append(fps, fp)
+		}
+	}
+	if next == -1 {
+		next = len(docs)
+	}
+	return page, next, collapsed
+}
+
+// FindDuplicates returns the clusters of near-duplicate documents of the index.
+// The first document of each cluster is the one with the most visits.
+func FindDuplicates() [][]*Document {
+	var docs []*Document
+	var fps []uint64
+	// near-duplicates differ in at most 3 bits, so at least one of the
+	// 4 16 bit bands of their fingerprints is identical
+	bands := make(map[[2]uint64][]int)
+	Iterate(func(d *Document) {
+		fp, ok := parseSimhash(d.Simhash)
+		if !ok {
+			return
+		}
+		d.HTML = ""
+		idx := len(docs)
+		docs = append(docs, d)
+		fps = append(fps, fp)
+		for b := range uint64(4) {
+			k := [2]uint64{b, (fp >> (b * 16)) & 0xffff}
+			bands[k] = append(bands[k], idx)
+		}
+	})
+	parent := make([]int, len(docs))
+	for j := range parent {
+		parent[j] = j
+	}
+	var find func(int) int
+	find = func(x int) int {
+		if parent[x] != x {
+			parent[x] = find(parent[x])
+		}
+		return parent[x]
+	}
+	for _, idxs := range bands {
+		for j := 0; j < len(idxs); j++ {
+			for k := j + 1; k < len(idxs); k++ {
+				a, b := idxs[j], idxs[k]
+				if isNearDuplicate(fps[a], fps[b]) {
+					parent[find(a)] = find(b)
+				}
+			}
+		}
+	}
+	groups := make(map[int][]*Document)
+	for j, d := range docs {
+		r := find(j)
+		groups[r] = append(groups[r], d)
+	}
+	clusters := make([][]*Document, 0)
+	for _, g := range groups {
+		if len(g) < 2 {
+			continue
+		}
+		sort.Slice(g, func(a, b int) bool {
+			if g[a].Visits != g[b].Visits {
+				return g[a].Visits > g[b].Visits
+			}
+			return len(g[a].URL) < len(g[b].URL)
+		})
+		clusters = append(clusters, g)
+	}
+	sort.Slice(clusters, func(a, b int) bool {
+		return clusters[a][0].URL < clusters[b][0].URL
+	})
+	return clusters
+}
+
+// MergeDuplicates keeps the first document of the cluster, moves the visits
+// of the other documents to it and deletes them from the index.
+func MergeDuplicates(cluster []*Document) error {
+	if len(cluster) < 2 {
+		return nil
+	}
+	keep := cluster[0]
+	var tags []string
+	var notes []string
+	for _, d := range cluster[1:] {
+		tags = append(tags, d.Tags...)
+		if d.Note != "" {
+			notes = append(notes, d.Note)
+		}
+		if model.DB != nil {
+			if err := model.MoveVisits(d.URL, keep.URL); err != nil {
+				return err
+			}
+		}
+		if err := Delete(d.URL); err != nil {
+			return err
+		}
+		log.Debug().Str("URL", d.URL).Str("Kept", keep.URL).Msg("Duplicate merged")
+	}
+	d := GetByURL(keep.URL)
+	if d == nil {
+		return fmt.Errorf("document not found: %s", keep.URL)
+	}
+	updateVisitStats(d)
+	d.Tags = normalizeTags(append(d.Tags, tags...))
+	if len(notes) > 0 {
+		d.Note = strings.TrimSpace(strings.Join(append([]string{d.Note}, notes...), "\n\n"))
+	}
+	return i.index(d)
+}
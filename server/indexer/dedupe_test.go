@@ -0,0 +1,222 @@
+package indexer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSimhash(t *testing.T) {
+	var sb strings.Builder
+	for j := range 300 {
+		fmt.Fprintf(&sb, "word%d ", j)
+	}
+	long := sb.String()
+	text := "the quick brown fox jumps over the lazy dog while the cat watches it from the window of the old house on the hill"
+	tests := []struct {
+		name      string
+		a, b      string
+		duplicate bool
+	}{
+		{
+			name:      "same text",
+			a:         text,
+			b:         text,
+			duplicate: true,
+		},
+		{
+			name:      "case and punctuation",
+			a:         text,
+			b:         strings.ToUpper(strings.ReplaceAll(text, " ", ", ")),
+			duplicate: true,
+		},
+		{
+			name:      "word appended to a long text",
+			a:         long,
+			b:         long + "appended",
+			duplicate: true,
+		},
+		{
+			name:      "different text",
+			a:         text,
+			b:         "lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor incididunt ut labore et dolore magna aliqua ut enim ad minim veniam",
+			duplicate: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a, ok := parseSimhash(simhash(tc.a))
+			if !ok {
+				t.Fatalf("simhash(%q) is not a valid fingerprint", tc.a)
+			}
+			b, ok := parseSimhash(simhash(tc.b))
+			if !ok {
+				t.Fatalf("simhash(%q) is not a valid fingerprint", tc.b)
+			}
+			if isNearDuplicate(a, b) != tc.duplicate {
+				t.Errorf("isNearDuplicate(%016x, %016x) = %v, want %v", a, b, !tc.duplicate, tc.duplicate)
+			}
+		})
+	}
+}
+
+func TestSimhashShortText(t *testing.T) {
+	for _, s := range []string{"", "a few words only", strings.Repeat("word ", simhashMinWords-1)} {
+		if fp := simhash(s); fp != "" {
+			t.Errorf("simhash(%q) = %q, want empty fingerprint", s, fp)
+		}
+	}
+}
+
+func TestCollapsePage(t *testing.T) {
+	const (
+		a  = "0000000000000000"
+		a1 = "0000000000000007" // 3 bits from a
+		b  = "ffffffffffffffff"
+		b1 = "fffffffffffffff0" // 4 bits from b
+	)
+	docs := func(fps ...string) []*Document {
+		ds := make([]*Document, len(fps))
+		for j, fp := range fps {
+			ds[j] = &Document{URL: string(rune('a' + j)), Simhash: fp}
+		}
+		return ds
+	}
+	tests := []struct {
+		name       string
+		docs       []*Document
+		limit      int
+		page       []string
+		duplicates map[string][]string
+		next       int
+		collapsed  int
+	}{
+		{
+			name:  "no duplicates",
+			docs:  docs(a, b, ""),
+			limit: 10,
+			page:  []string{"a", "b", "c"},
+			next:  3,
+		},
+		{
+			name:       "duplicate of the first document",
+			docs:       docs(a, b, a1, ""),
+			limit:      10,
+			page:       []string{"a", "b", "d"},
+			duplicates: map[string][]string{"a": {"c"}},
+			next:       4,
+			collapsed:  1,
+		},
+		{
+			name:  "documents without fingerprint are not collapsed",
+			docs:  docs("", "", a),
+			limit: 10,
+			page:  []string{"a", "b", "c"},
+			next:  3,
+		},
+		{
+			name:  "4 bits difference is not a duplicate",
+			docs:  docs(b, b1),
+			limit: 10,
+			page:  []string{"a", "b"},
+			next:  2,
+		},
+		{
+			name:       "duplicates after the page are collapsed",
+			docs:       docs(a, b, "", a1, ""),
+			limit:      2,
+			page:       []string{"a", "b"},
+			duplicates: map[string][]string{"a": {"d"}},
+			next:       2,
+			collapsed:  1,
+		},
+		{
+			name:       "duplicates replaced by the next documents",
+			docs:       docs(a, a1, a1, b, ""),
+			limit:      2,
+			page:       []string{"a", "d"},
+			duplicates: map[string][]string{"a": {"b", "c"}},
+			next:       4,
+			collapsed:  2,
+		},
+		{
+			name:  "empty list",
+			docs:  nil,
+			limit: 2,
+			page:  nil,
+			next:  0,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			page, next, collapsed := collapsePage(tc.docs, tc.limit)
+			var urls []string
+			for _, d := range page {
+				urls = append(urls, d.URL)
+			}
+			if !slices.Equal(urls, tc.page) {
+				t.Errorf("page = %v, want %v", urls, tc.page)
+			}
+			if next != tc.next {
+				t.Errorf("next = %d, want %d", next, tc.next)
+			}
+			if collapsed != tc.collapsed {
+				t.Errorf("collapsed = %d, want %d", collapsed, tc.collapsed)
+			}
+			for _, d := range tc.docs {
+				if !slices.Equal(d.Duplicates, tc.duplicates[d.URL]) {
+					t.Errorf("duplicates of %s = %v, want %v", d.URL, d.Duplicates, tc.duplicates[d.URL])
+				}
+			}
+		})
+	}
+}
+
+func TestSearchCollapsedPaging(t *testing.T) {
+	cfg := initTestIndex(t)
+	const groups = 8
+	for g := range groups {
+		var sb strings.Builder
+		for j := range 30 {
+			fmt.Fprintf(&sb, "group%d word%d ", g, j)
+		}
+		for j := range 3 {
+			addTestDocument(t, fmt.Sprintf("https://example.com/%d/%d", g, j), "Copy", "duplicate "+sb.String())
+		}
+	}
+	var urls []string
+	q := &Query{Text: "duplicate", Sort: "url", Limit: 3}
+	for pages := 0; ; pages++ {
+		if pages > groups {
+			t.Fatal("too many pages")
+		}
+		r, err := Search(cfg, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pages == 0 && r.Total != groups {
+			t.Errorf("total = %d, want %d", r.Total, groups)
+		}
+		for _, d := range r.Documents {
+			urls = append(urls, d.URL)
+			if len(d.Duplicates) != 2 {
+				t.Errorf("%s has %d duplicates, want 2", d.URL, len(d.Duplicates))
+			}
+		}
+		if !r.HasMore {
+			break
+		}
+		if len(r.Documents) == 0 {
+			t.Fatal("empty page with more results")
+		}
+		q = &Query{Text: "duplicate", Sort: "url", Limit: 3, After: r.Next}
+	}
+	var want []string
+	for g := range groups {
+		want = append(want, fmt.Sprintf("https://example.com/%d/0", g))
+	}
+	if !slices.Equal(urls, want) {
+		t.Errorf("urls = %q, want %q", urls, want)
+	}
+}
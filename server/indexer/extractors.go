@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"maps"
 	"net/url"
 	"strings"
 
@@ -19,6 +20,16 @@ type Extractor interface {
 }
 
 var extractors []Extractor = []Extractor{
+	&pdfExtractor{},
+	&plainTextExtractor{},
+	&markdownExtractor{},
+	&jsonExtractor{},
+	&codeExtractor{},
+	&githubExtractor{},
+	&stackOverflowExtractor{},
+	&redditExtractor{},
+	&hackerNewsExtractor{},
+	&mdnExtractor{},
 	&readabilityExtractor{},
 	&defaultExtractor{},
 }
@@ -31,13 +42,22 @@ type readabilityExtractor struct{}
 
 func Extract(d *Document) error {
 	for _, e := range extractors {
-		if e.Match(d) {
-			if err := e.Extract(d); err != nil {
-				log.Warn().Err(err).Str("URL", d.URL).Str("Extractor", e.Name()).Msg("Failed to extract content")
-			} else {
-				return nil
-			}
+		if !e.Match(d) {
+			continue
+		}
+		// extract into a copy to drop the fields set by a failed extractor
+		c := *d
+		c.Meta = maps.Clone(d.Meta)
+		if err := e.Extract(&c); err != nil {
+			log.Warn().Err(err).Str("URL", d.URL).Str("Extractor", e.Name()).Msg("Failed to extract content")
+			continue
 		}
+		*d = c
+		d.extractMetadata()
+		d.extractLinks()
+		d.extractHeadings()
+		d.extractCode()
+		return nil
 	}
 	return ErrNoExtractor
 }
@@ -46,8 +66,8 @@ func (e *defaultExtractor) Name() string {
 	return "Default"
 }
 
-func (e *defaultExtractor) Match(_ *Document) bool {
-	return true
+func (e *defaultExtractor) Match(d *Document) bool {
+	return d.isHTML()
 }
 
 func (e *defaultExtractor) Extract(d *Document) error {
@@ -105,8 +125,8 @@ func (e *readabilityExtractor) Name() string {
 	return "Readability"
 }
 
-func (e *readabilityExtractor) Match(_ *Document) bool {
-	return true
+func (e *readabilityExtractor) Match(d *Document) bool {
+	return d.isHTML()
 }
 
 func (e *readabilityExtractor) Extract(d *Document) error {
@@ -0,0 +1,80 @@
+package indexer
+
+import (
+	"errors"
+	"testing"
+)
+
+// failingExtractor sets every extracted field and fails
+type failingExtractor struct{}
+
+func (e *failingExtractor) Name() string {
+	return "Failing"
+}
+
+func (e *failingExtractor) Match(d *Document) bool {
+	return true
+}
+
+func (e *failingExtractor) Extract(d *Document) error {
+	d.Title = "partial title"
+	d.Text = "partial text"
+	d.ContentType = "text/partial"
+	d.Author = "partial author"
+	d.Published = 1
+	d.Description = "partial description"
+	d.SiteName = "partial site"
+	d.Image = "https://example.com/partial.png"
+	d.faviconURL = "https://example.com/partial.ico"
+	d.Links = []string{"https://example.com/partial"}
+	d.Headings = []*Heading{{Text: "partial heading"}}
+	d.Code = "partial code"
+	d.setMeta("partial", "meta")
+	return errors.New("extraction failed")
+}
+
+func TestExtractFallback(t *testing.T) {
+	orig := extractors
+	extractors = []Extractor{&failingExtractor{}, &defaultExtractor{}}
+	t.Cleanup(func() {
+		extractors = orig
+	})
+	meta := map[string]string{"stored": "meta"}
+	d := &Document{
+		URL:  "https://example.com/",
+		HTML: "<html><head><title>Title</title></head><body><p>text</p></body></html>",
+		Meta: meta,
+	}
+	if err := Extract(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "Title" || d.Text != "text" {
+		t.Errorf("title, text = %q, %q, want the result of the fallback extractor", d.Title, d.Text)
+	}
+	partial := map[string]bool{
+		"content type": d.ContentType != "",
+		"author":       d.Author != "",
+		"published":    d.Published != 0,
+		"description":  d.Description != "",
+		"site name":    d.SiteName != "",
+		"image":        d.Image != "",
+		"favicon":      d.faviconURL != "",
+		"links":        len(d.Links) > 0,
+		"headings":     len(d.Headings) > 0,
+		"code":         d.Code != "",
+		"meta":         d.Meta["partial"] != "",
+	}
+	for f, set := range partial {
+		if set {
+			t.Errorf("%s of the failed extractor is kept", f)
+		}
+	}
+	if _, ok := meta["partial"]; ok {
+		t.Error("failed extractor modified the metadata of the document")
+	}
+
+	extractors = []Extractor{&failingExtractor{}}
+	if err := Extract(d); !errors.Is(err, ErrNoExtractor) {
+		t.Errorf("Extract() error = %v, want ErrNoExtractor", err)
+	}
+}
@@ -0,0 +1,121 @@
+package indexer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+const domainFacetSize = 10
+
+type Facets struct {
+	Domains []*TermFacet `json:"domains"`
+	Dates   []*DateFacet `json:"dates"`
+}
+
+type TermFacet struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+type DateFacet struct {
+	Name  string `json:"name"`
+	From  int64  `json:"from"`
+	To    int64  `json:"to"`
+	Count int    `json:"count"`
+}
+
+type dateInterval struct {
+	buckets int
+	start   func(time.Time) time.Time
+	next    func(time.Time) time.Time
+	format  string
+}
+
+var dateIntervals = map[string]*dateInterval{
+	"day": {
+		buckets: 31,
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		},
+		next:   func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+		format: "2006-01-02",
+	},
+	"week": {
+		buckets: 26,
+		start: func(t time.Time) time.Time {
+			d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+			return d.AddDate(0, 0, -(int(d.Weekday())+6)%7)
+		},
+		next:   func(t time.Time) time.Time { return t.AddDate(0, 0, 7) },
+		format: "2006-01-02",
+	},
+	"month": {
+		buckets: 24,
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		},
+		next:   func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+		format: "2006-01",
+	},
+}
+
+// dateBuckets returns the empty histogram buckets of the requested interval ending with the current one.
+func (q *Query) dateBuckets() ([]*DateFacet, error) {
+	interval := q.FacetInterval
+	if interval == "" {
+		interval = "month"
+	}
+	di, ok := dateIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid facet interval %q", ErrInvalidQuery, interval)
+	}
+	end := di.next(di.start(time.Now()))
+	start := end
+	for range di.buckets {
+		start = di.start(start.Add(-time.Second))
+	}
+	bs := make([]*DateFacet, 0, di.buckets)
+	for t := start; t.Before(end); t = di.next(t) {
+		bs = append(bs, &DateFacet{
+			Name: t.Format(di.format),
+			From: t.Unix(),
+			To:   di.next(t).Unix(),
+		})
+	}
+	return bs, nil
+}
+
+func addFacetRequests(req *bleve.SearchRequest, buckets []*DateFacet) {
+	req.AddFacet("domain", bleve.NewFacetRequest("domain", domainFacetSize))
+	df := bleve.NewFacetRequest("added", len(buckets))
+	for _, b := range buckets {
+		from := float64(b.From)
+		to := float64(b.To)
+		df.AddNumericRange(b.Name, &from, &to)
+	}
+	req.AddFacet("added", df)
+}
+
+func facetsFromResult(res *bleve.SearchResult, buckets []*DateFacet) *Facets {
+	f := &Facets{
+		Domains: make([]*TermFacet, 0),
+		Dates:   buckets,
+	}
+	if df, ok := res.Facets["domain"]; ok && df.Terms != nil {
+		for _, t := range df.Terms.Terms() {
+			f.Domains = append(f.Domains, &TermFacet{Term: t.Term, Count: t.Count})
+		}
+	}
+	if af, ok := res.Facets["added"]; ok {
+		counts := make(map[string]int, len(af.NumericRanges))
+		for _, r := range af.NumericRanges {
+			counts[r.Name] = r.Count
+		}
+		for _, b := range f.Dates {
+			b.Count = counts[b.Name]
+		}
+	}
+	return f
+}
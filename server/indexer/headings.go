@@ -0,0 +1,228 @@
+package indexer
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search"
+	"golang.org/x/net/html"
+)
+
+// maxHeadings is the maximum number of headings stored per document
+const maxHeadings = 200
+
+// textDirective is the prefix of URL fragments highlighting text in the browser
+const textDirective = ":~:text="
+
+// Heading is a section heading of a document
+type Heading struct {
+	Text string `json:"text"`
+	// Anchor is the URL fragment of the section, the id of the heading element
+	// or a text directive matching the heading if it has no id
+	Anchor string `json:"anchor"`
+}
+
+func headingsMapping() *mapping.DocumentMapping {
+	fm := bleve.NewTextFieldMapping()
+	fm.Store = true
+	noIdxMap := bleve.NewTextFieldMapping()
+	noIdxMap.Index = false
+	dm := bleve.NewDocumentMapping()
+	dm.AddFieldMappingsAt("text", fm)
+	dm.AddFieldMappingsAt("anchor", noIdxMap)
+	return dm
+}
+
+// extractHeadings collects the h1-h4 outline of HTML documents
+func (d *Document) extractHeadings() {
+	if d.HTML == "" {
+		return
+	}
+	doc, err := parseDocument(d)
+	if err != nil {
+		return
+	}
+	d.Headings = nil
+	for _, n := range selectAll(doc, "body h1, body h2, body h3, body h4") {
+		t := strings.TrimRight(strings.Join(strings.Fields(nodeText(n)), " "), " ¶#§🔗")
+		if t == "" {
+			continue
+		}
+		d.Headings = append(d.Headings, &Heading{
+			Text:   t,
+			Anchor: headingAnchor(n, t),
+		})
+		if len(d.Headings) == maxHeadings {
+			return
+		}
+	}
+}
+
+// setHeadings stores headings without element ids
+func (d *Document) setHeadings(hs []string) {
+	d.Headings = nil
+	for _, h := range hs {
+		if h = strings.Join(strings.Fields(h), " "); h == "" {
+			continue
+		}
+		d.Headings = append(d.Headings, &Heading{
+			Text:   h,
+			Anchor: textFragment(h),
+		})
+		if len(d.Headings) == maxHeadings {
+			return
+		}
+	}
+}
+
+// headingAnchor returns the id of a heading, of its anchor elements
+// or of the empty anchor element preceding it
+func headingAnchor(n *html.Node, text string) string {
+	if id := attr(n, "id"); id != "" {
+		return id
+	}
+	for _, c := range selectAll(n, "[id], a[name]") {
+		if c == n {
+			continue
+		}
+		if id := firstNonEmpty(attr(c, "id"), attr(c, "name")); id != "" {
+			return id
+		}
+	}
+	for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+		if s.Type == html.TextNode && strings.TrimSpace(s.Data) == "" {
+			continue
+		}
+		if s.Type == html.ElementNode && s.Data == "a" && s.FirstChild == nil {
+			if id := firstNonEmpty(attr(s, "id"), attr(s, "name")); id != "" {
+				return id
+			}
+		}
+		break
+	}
+	return textFragment(text)
+}
+
+// textFragment returns a text directive matching the given text
+func textFragment(s string) string {
+	words := strings.Fields(s)
+	if len(words) > 8 {
+		return textDirective + encodeTextDirective(strings.Join(words[:4], " ")) + "," + encodeTextDirective(strings.Join(words[len(words)-4:], " "))
+	}
+	return textDirective + encodeTextDirective(strings.Join(words, " "))
+}
+
+func encodeTextDirective(s string) string {
+	return strings.NewReplacer("-", "%2D", ",", "%2C", "&", "%26").Replace(url.PathEscape(s))
+}
+
+func readHeadings(h *search.DocumentMatch) []*Heading {
+	texts := readStringList(h, "headings.text")
+	anchors := readStringList(h, "headings.anchor")
+	if len(texts) != len(anchors) {
+		return nil
+	}
+	hs := make([]*Heading, len(texts))
+	for n := range texts {
+		hs[n] = &Heading{
+			Text:   texts[n],
+			Anchor: anchors[n],
+		}
+	}
+	return hs
+}
+
+// firstTextMatch returns the byte offsets of the first match of the text fields
+func firstTextMatch(h *search.DocumentMatch) (int, int, bool) {
+	start, end := -1, -1
+	for field, tlm := range h.Locations {
+		if field != "text" && !strings.HasPrefix(field, "text_") {
+			continue
+		}
+		for _, locs := range tlm {
+			for _, l := range locs {
+				if start == -1 || int(l.Start) < start {
+					start, end = int(l.Start), int(l.End)
+				}
+			}
+		}
+	}
+	return start, end, start != -1
+}
+
+// anchorOfHit returns the URL fragment pointing to the best match of an HTML document:
+// the anchor of a matching heading or a text directive of the first text match
+// preceded by the id of its section if it is known
+func anchorOfHit(h *search.DocumentMatch) string {
+	hs := readHeadings(h)
+	if tlm, ok := h.Locations["headings.text"]; ok {
+		idx := -1
+		for _, locs := range tlm {
+			for _, l := range locs {
+				if len(l.ArrayPositions) > 0 && (idx == -1 || int(l.ArrayPositions[0]) < idx) {
+					idx = int(l.ArrayPositions[0])
+				}
+			}
+		}
+		if idx >= 0 && idx < len(hs) {
+			return hs[idx].Anchor
+		}
+	}
+	text, ok := h.Fields["text"].(string)
+	if !ok {
+		return ""
+	}
+	start, end, ok := firstTextMatch(h)
+	if !ok || end > len(text) {
+		return ""
+	}
+	return sectionID(text, start, hs) + textFragment(matchContext(text, start, end))
+}
+
+// matchContext returns the match with a few surrounding words from the same line
+func matchContext(text string, start, end int) string {
+	const context = 3
+	ls := strings.LastIndexByte(text[:start], '\n') + 1
+	le := strings.IndexByte(text[end:], '\n')
+	if le == -1 {
+		le = len(text)
+	} else {
+		le += end
+	}
+	before := strings.Fields(text[ls:start])
+	after := strings.Fields(text[end:le])
+	// the match can end inside a word
+	if end < le && len(after) > 0 && text[end] != ' ' && text[end] != '\t' {
+		after = after[1:]
+	}
+	before = before[max(0, len(before)-context):]
+	after = after[:min(len(after), context)]
+	word := text[start:end]
+	if end < le && text[end] != ' ' && text[end] != '\t' {
+		word += strings.Fields(text[end:le])[0]
+	}
+	return strings.Join(append(append(before, word), after...), " ")
+}
+
+// sectionID returns the element id of the closest heading preceding the offset
+func sectionID(text string, offset int, hs []*Heading) string {
+	id := ""
+	pos := 0
+	for _, h := range hs {
+		idx := strings.Index(text[pos:], h.Text)
+		if idx == -1 {
+			continue
+		}
+		if pos+idx > offset {
+			break
+		}
+		pos += idx + len(h.Text)
+		id = ""
+		if !strings.HasPrefix(h.Anchor, textDirective) {
+			id = h.Anchor
+		}
+	}
+	return id
+}
@@ -0,0 +1,228 @@
+package indexer
+
+import (
+	"maps"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/asciimoo/hister/server/indexer/querybuilder"
+
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/highlight"
+	htmlFormatter "github.com/blevesearch/bleve/v2/search/highlight/format/html"
+	simpleFragmenter "github.com/blevesearch/bleve/v2/search/highlight/fragmenter/simple"
+	simpleHighlighter "github.com/blevesearch/bleve/v2/search/highlight/highlighter/simple"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Highlight styles of the search results
+const (
+	HighlightHTML = "HTML"
+	// HighlightText marks the matches with inverted ANSI colors
+	HighlightText = "text"
+	HighlightTUI  = "tui"
+	// HighlightStructured returns plain text fragments with the offsets of the matches
+	HighlightStructured = "structured"
+)
+
+const (
+	defaultFragmentSize = 200
+	minFragmentSize     = 20
+	maxFragmentSize     = 2000
+	maxFragments        = 10
+)
+
+// Snippet is a plain text fragment of a document field returned by the structured highlight style.
+// Offsets are counted in characters (Unicode code points).
+type Snippet struct {
+	Field string `json:"field"`
+	Text  string `json:"text"`
+	// Offset is the position of the fragment in the field
+	Offset int `json:"offset"`
+	// Matches are the positions of the matches in the fragment
+	Matches []*MatchRange `json:"matches"`
+}
+
+// MatchRange is the [Start, End) character range of a match
+type MatchRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type highlighter struct {
+	fragmenter highlight.Fragmenter
+	// formatter is nil for the structured style
+	formatter highlight.FragmentFormatter
+	num       int
+}
+
+// highlighter returns the highlighter of the query or nil if highlighting is not requested
+func (q *Query) highlighter() *highlighter {
+	hl := &highlighter{
+		fragmenter: simpleFragmenter.NewFragmenter(defaultFragmentSize),
+		num:        1,
+	}
+	switch q.Highlight {
+	case HighlightHTML:
+		hl.formatter = htmlFormatter.NewFragmentFormatter("<mark>", "</mark>")
+	case HighlightText:
+		hl.formatter = newLipglossFormatter(lipgloss.NewStyle().Reverse(true))
+	case HighlightTUI:
+		hl.formatter = newLipglossFormatter(lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true))
+	case HighlightStructured:
+	default:
+		return nil
+	}
+	if q.FragmentSize > 0 {
+		hl.fragmenter = simpleFragmenter.NewFragmenter(min(max(q.FragmentSize, minFragmentSize), maxFragmentSize))
+	}
+	if q.Fragments > 0 {
+		hl.num = min(q.Fragments, maxFragments)
+	}
+	return hl
+}
+
+// highlight sets the fragments of the stored fields having matches
+// and returns the snippets of the structured style
+func (hl *highlighter) highlight(h *search.DocumentMatch) []*Snippet {
+	h.Fragments = make(search.FieldFragmentMap)
+	var snippets []*Snippet
+	locations := hitLocations(h)
+	for _, field := range slices.Sorted(maps.Keys(locations)) {
+		tlm := locations[field]
+		otl := highlight.OrderTermLocations(tlm)
+		otl.MergeOverlapping()
+		var frags []*highlight.Fragment
+		scorer := simpleHighlighter.NewFragmentScorer(tlm)
+		for n, v := range fieldValues(h, field) {
+			var ap search.ArrayPositions
+			if _, ok := h.Fields[field].([]any); ok {
+				ap = search.ArrayPositions{uint64(n)}
+			}
+			var vtl highlight.TermLocations
+			for _, tl := range otl {
+				if tl != nil && tl.ArrayPositions.Equals(ap) {
+					vtl = append(vtl, tl)
+				}
+			}
+			if len(vtl) == 0 {
+				continue
+			}
+			for _, f := range hl.fragmenter.Fragment([]byte(v), vtl) {
+				f.ArrayPositions = ap
+				scorer.Score(f)
+				frags = append(frags, f)
+			}
+		}
+		for _, f := range bestFragments(frags, hl.num) {
+			if hl.formatter == nil {
+				s := structuredSnippet(f, otl)
+				s.Field = field
+				snippets = append(snippets, s)
+				h.Fragments[field] = append(h.Fragments[field], s.Text)
+				continue
+			}
+			t := hl.formatter.Format(f, otl)
+			if f.Start != 0 {
+				t = simpleHighlighter.DefaultSeparator + t
+			}
+			if f.End != len(f.Orig) {
+				t += simpleHighlighter.DefaultSeparator
+			}
+			h.Fragments[field] = append(h.Fragments[field], t)
+		}
+	}
+	return snippets
+}
+
+// hitLocations returns the match locations of the hit by field.
+// The locations of the language analyzed fields are added to the
+// stored title and text fields as they have the same offsets.
+func hitLocations(h *search.DocumentMatch) map[string]search.TermLocationMap {
+	ret := make(map[string]search.TermLocationMap)
+	for field, tlm := range h.Locations {
+		for _, f := range []string{"title", "text"} {
+			for _, l := range querybuilder.Languages {
+				if field == f+"_"+l {
+					field = f
+				}
+			}
+		}
+		if ret[field] == nil {
+			ret[field] = make(search.TermLocationMap)
+		}
+		for term, locs := range tlm {
+			ret[field][term] = append(ret[field][term], locs...)
+		}
+	}
+	return ret
+}
+
+func fieldValues(h *search.DocumentMatch, field string) []string {
+	switch v := h.Fields[field].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		ret := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				ret = append(ret, s)
+			}
+		}
+		return ret
+	}
+	return nil
+}
+
+// bestFragments returns the num highest scored non-overlapping fragments in document order
+func bestFragments(frags []*highlight.Fragment, num int) []*highlight.Fragment {
+	slices.SortStableFunc(frags, func(a, b *highlight.Fragment) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	var ret []*highlight.Fragment
+	for _, f := range frags {
+		if len(ret) == num {
+			break
+		}
+		overlaps := slices.ContainsFunc(ret, func(o *highlight.Fragment) bool {
+			return search.ArrayPositions(o.ArrayPositions).Equals(f.ArrayPositions) && o.Overlaps(f)
+		})
+		if !overlaps {
+			ret = append(ret, f)
+		}
+	}
+	slices.SortFunc(ret, func(a, b *highlight.Fragment) int {
+		if c := search.ArrayPositions(a.ArrayPositions).Compare(b.ArrayPositions); c != 0 {
+			return c
+		}
+		return a.Start - b.Start
+	})
+	return ret
+}
+
+func structuredSnippet(f *highlight.Fragment, otl highlight.TermLocations) *Snippet {
+	s := &Snippet{
+		Text:    string(f.Orig[f.Start:f.End]),
+		Offset:  utf8.RuneCount(f.Orig[:f.Start]),
+		Matches: make([]*MatchRange, 0),
+	}
+	curr := f.Start
+	for _, tl := range otl {
+		if tl == nil || !tl.ArrayPositions.Equals(f.ArrayPositions) || tl.Start < curr || tl.End > f.End {
+			continue
+		}
+		start := utf8.RuneCount(f.Orig[f.Start:tl.Start])
+		s.Matches = append(s.Matches, &MatchRange{
+			Start: start,
+			End:   start + utf8.RuneCount(f.Orig[tl.Start:tl.End]),
+		})
+		curr = tl.End
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+package indexer
+
+import (
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/search/highlight"
+)
+
+func TestStructuredSnippet(t *testing.T) {
+	orig := []byte("héllo wörld, hello world")
+	tests := []struct {
+		name    string
+		frag    *highlight.Fragment
+		tls     highlight.TermLocations
+		text    string
+		offset  int
+		matches []MatchRange
+	}{
+		{
+			name:    "whole field",
+			frag:    &highlight.Fragment{Orig: orig, Start: 0, End: len(orig)},
+			tls:     highlight.TermLocations{{Start: 0, End: 6}, {Start: 15, End: 20}},
+			text:    "héllo wörld, hello world",
+			matches: []MatchRange{{0, 5}, {13, 18}},
+		},
+		{
+			name:    "character offsets after multibyte characters",
+			frag:    &highlight.Fragment{Orig: orig, Start: 7, End: len(orig)},
+			tls:     highlight.TermLocations{{Start: 7, End: 13}, {Start: 21, End: 26}},
+			text:    "wörld, hello world",
+			offset:  6,
+			matches: []MatchRange{{0, 5}, {13, 18}},
+		},
+		{
+			name:    "matches outside of the fragment",
+			frag:    &highlight.Fragment{Orig: orig, Start: 7, End: 13},
+			tls:     highlight.TermLocations{{Start: 0, End: 6}, {Start: 7, End: 13}, {Start: 15, End: 20}},
+			text:    "wörld",
+			offset:  6,
+			matches: []MatchRange{{0, 5}},
+		},
+		{
+			name:    "overlapping matches",
+			frag:    &highlight.Fragment{Orig: orig, Start: 0, End: len(orig)},
+			tls:     highlight.TermLocations{{Start: 15, End: 20}, {Start: 17, End: 20}, nil},
+			text:    "héllo wörld, hello world",
+			matches: []MatchRange{{13, 18}},
+		},
+		{
+			name:    "matches of other array elements",
+			frag:    &highlight.Fragment{Orig: orig, ArrayPositions: []uint64{1}, Start: 0, End: 6},
+			tls:     highlight.TermLocations{{Start: 0, End: 6}, {ArrayPositions: []uint64{1}, Start: 0, End: 6}},
+			text:    "héllo",
+			matches: []MatchRange{{0, 5}},
+		},
+		{
+			name: "no matches",
+			frag: &highlight.Fragment{Orig: orig, Start: 0, End: 6},
+			text: "héllo",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := structuredSnippet(tc.frag, tc.tls)
+			if s.Text != tc.text {
+				t.Errorf("text = %q, want %q", s.Text, tc.text)
+			}
+			if s.Offset != tc.offset {
+				t.Errorf("offset = %d, want %d", s.Offset, tc.offset)
+			}
+			if s.Matches == nil {
+				t.Error("matches = nil, want an empty list")
+			}
+			if len(s.Matches) != len(tc.matches) {
+				t.Fatalf("matches = %d, want %d", len(s.Matches), len(tc.matches))
+			}
+			for j, m := range s.Matches {
+				if *m != tc.matches[j] {
+					t.Errorf("match %d = %v, want %v", j, *m, tc.matches[j])
+				}
+			}
+		})
+	}
+}
@@ -6,11 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/url"
-	"os"
-	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/asciimoo/hister/config"
@@ -19,72 +20,129 @@ import (
 
 	"github.com/blevesearch/bleve/v2"
 	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	regexpTokenizer "github.com/blevesearch/bleve/v2/analysis/tokenizer/regexp"
 	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
 	"github.com/blevesearch/bleve/v2/mapping"
-	"github.com/blevesearch/bleve/v2/registry"
 	"github.com/blevesearch/bleve/v2/search"
 	"github.com/blevesearch/bleve/v2/search/highlight"
-	simpleFragmenter "github.com/blevesearch/bleve/v2/search/highlight/fragmenter/simple"
-	simpleHighlighter "github.com/blevesearch/bleve/v2/search/highlight/highlighter/simple"
 	"github.com/blevesearch/bleve/v2/search/query"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/rs/zerolog/log"
 )
 
-var Version = 1
+var Version = 13
 
 type indexer struct {
+	mu  sync.RWMutex
 	idx bleve.Index
+	// tmp receives a copy of every write while an online reindex is running
+	tmp   bleve.Index
+	tmpMu sync.Mutex
+	// dirty holds the URLs written to tmp by live updates during an online reindex
+	dirty map[string]bool
+	// langs holds the analyzers of the languages of the indexed documents
+	langs  map[string]bool
+	langMu sync.RWMutex
 }
 
 type Query struct {
-	Text      string `json:"text"`
-	Highlight string `json:"highlight"`
-	Limit     int    `json:"limit"`
-	Sort      string `json:"sort"`
-	DateFrom  int64  `json:"date_from"`
-	DateTo    int64  `json:"date_to"`
-	cfg       *config.Config
+	Text          string `json:"text"`
+	Highlight     string `json:"highlight"`
+	Fragments     int    `json:"fragments"`
+	FragmentSize  int    `json:"fragment_size"`
+	Limit         int    `json:"limit"`
+	Offset        int    `json:"offset"`
+	After         string `json:"after"` // cursor of the page from Results.Next, overrides Offset
+	Sort          string `json:"sort"`
+	DateFrom      int64  `json:"date_from"`
+	DateTo        int64  `json:"date_to"`
+	Facets        bool   `json:"facets"`
+	FacetInterval string `json:"facet_interval"`
+	NoCorrection  bool   `json:"no_correction"`
+	cfg           *config.Config
 }
 
 type Document struct {
-	URL                string  `json:"url"`
-	Domain             string  `json:"domain"`
-	HTML               string  `json:"html"`
-	Title              string  `json:"title"`
-	Text               string  `json:"text"`
-	Favicon            string  `json:"favicon"`
-	Score              float64 `json:"score"`
-	Added              int64   `json:"added"`
+	URL                string            `json:"url"`
+	Domain             string            `json:"domain"`
+	HTML               string            `json:"html"`
+	Title              string            `json:"title"`
+	Text               string            `json:"text"`
+	Favicon            string            `json:"favicon"`
+	Score              float64           `json:"score"`
+	Added              int64             `json:"added"`
+	Visits             int               `json:"visits"`
+	FirstSeen          int64             `json:"first_seen"`
+	LastSeen           int64             `json:"last_seen"`
+	Simhash            string            `json:"simhash"`
+	Language           string            `json:"lang"`
+	Hash               string            `json:"hash"`
+	Tags               []string          `json:"tags"`
+	Note               string            `json:"note"`
+	ContentType        string            `json:"content_type"`
+	Data               string            `json:"data,omitempty"`
+	Author             string            `json:"author"`
+	Published          int64             `json:"published"`
+	Description        string            `json:"description"`
+	SiteName           string            `json:"site_name"`
+	Image              string            `json:"image"`
+	Page               int               `json:"page,omitempty"`
+	Anchor             string            `json:"anchor,omitempty"`
+	Meta               map[string]string `json:"meta,omitempty"`
+	Links              []string          `json:"links,omitempty"`
+	Headings           []*Heading        `json:"headings,omitempty"`
+	Code               string            `json:"code,omitempty"`
+	Fragments          []string          `json:"fragments,omitempty"`
+	Snippets           []*Snippet        `json:"snippets,omitempty"`
+	Duplicates         []string          `json:"duplicates,omitempty"`
 	faviconURL         string
 	processed          bool
+	unchanged          bool
+	firstSeen          int64
 	skipSensitiveCheck bool
+	sensitiveMatches   []*SensitiveMatch
+	// indexed is the stored version of an unchanged document
+	indexed *Document
+	// submitted annotations of the document before merging them with the indexed ones
+	ownTags      []string
+	ownNote      string
+	hasOwnFields bool
 }
 
 type Results struct {
-	Total           uint64            `json:"total"`
-	Query           *Query            `json:"query"`
-	Documents       []*Document       `json:"documents"`
-	History         []*model.URLCount `json:"history"`
-	SearchDuration  string            `json:"search_duration"`
-	QuerySuggestion string            `json:"query_suggestion"`
+	// Total is an estimate of the number of results, only the near-duplicates
+	// fetched with the page are subtracted from the number of hits
+	Total  uint64 `json:"total"`
+	Offset int    `json:"offset"`
+	// NextOffset is the offset of the next page, it can exceed Offset+len(Documents)
+	// as the collapsed near-duplicates are skipped
+	NextOffset      int                 `json:"next_offset"`
+	Next            string              `json:"next,omitempty"` // cursor of the next page, see Query.After
+	HasMore         bool                `json:"has_more"`
+	Query           *Query              `json:"query"`
+	Documents       []*Document         `json:"documents"`
+	History         []*model.URLCount   `json:"history"`
+	Error           string              `json:"error,omitempty"`
+	SearchDuration  string              `json:"search_duration"`
+	QuerySuggestion string              `json:"query_suggestion"`
+	Spelling        *SpellingCorrection `json:"spelling,omitempty"`
+	Facets          *Facets             `json:"facets,omitempty"`
 }
 
 var (
-	i                   *indexer
-	allFields           []string = []string{"url", "title", "text", "favicon", "html", "domain", "added"}
+	i            *indexer
+	allFields    []string = append([]string{"url", "title", "text", "favicon", "html", "domain", "added", "visits", "first_seen", "last_seen", "simhash", "lang", "hash", "tags", "note", "content_type", "author", "published", "description", "site_name", "image", "headings.text", "headings.anchor", "code"}, metaFields()...)
+	storedFields []string = append(slices.Clone(allFields), "data", "links")
+	// rankFields are used to rank and collapse the hits before loading the documents of the result page
+	rankFields          []string = []string{"simhash", "added", "visits", "first_seen", "last_seen"}
 	ErrSensitiveContent          = errors.New("document contains sensitive data")
-	sensitiveContentRe  *regexp.Regexp
+	ErrInvalidQuery              = errors.New("invalid query")
 	sanitizer           *bluemonday.Policy
 )
 
 func Init(cfg *config.Config) error {
-	sp := make([]string, 0, len(cfg.SensitiveContentPatterns))
-	for _, v := range cfg.SensitiveContentPatterns {
-		sp = append(sp, v)
-	}
-	sensitiveContentRe = regexp.MustCompile(fmt.Sprintf("(%s)", strings.Join(sp, "|")))
+	configure(cfg)
 	idx, err := bleve.Open(cfg.IndexPath())
 	if err != nil {
 		mapping := createMapping()
@@ -96,156 +154,334 @@ func Init(cfg *config.Config) error {
 	i = &indexer{
 		idx: idx,
 	}
-	registry.RegisterHighlighter("ansi", invertedAnsiHighlighter)
-	registry.RegisterHighlighter("tui", tuiHighlighter)
-	return nil
-}
-
-func init() {
-	sanitizer = bluemonday.StrictPolicy()
+	return i.loadLanguages()
 }
 
-func Reindex(idxPath, tmpIdxPath string, rules *config.Rules, skipSensitiveChecks bool) error {
-	idx, err := bleve.Open(idxPath)
-	if err != nil {
+func (ix *indexer) index(d *Document) error {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	if err := ix.idx.Index(d.URL, d); err != nil {
 		return err
 	}
-	mapping := createMapping()
-	tmpIdx, err := bleve.New(tmpIdxPath, mapping)
-	if err != nil {
+	ix.addLanguage(d.Language)
+	if ix.tmp != nil {
+		ix.tmpMu.Lock()
+		defer ix.tmpMu.Unlock()
+		ix.dirty[d.URL] = true
+		if err := ix.tmp.Index(d.URL, d); err != nil {
+			log.Warn().Err(err).Str("URL", d.URL).Msg("Failed to add document to the reindexed index")
+		}
+	}
+	return nil
+}
+
+func (ix *indexer) delete(u string) error {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	if err := ix.idx.Delete(u); err != nil {
 		return err
 	}
-	q := query.NewMatchAllQuery()
-	resultNum := 20
-	page := 0
-	for {
-		req := bleve.NewSearchRequest(q)
-		req.Size = resultNum
-		req.From = page * resultNum
-		req.Fields = allFields
-		res, err := idx.Search(req)
-		if err != nil || len(res.Hits) < 1 {
-			break
+	if ix.tmp != nil {
+		ix.tmpMu.Lock()
+		defer ix.tmpMu.Unlock()
+		ix.dirty[u] = true
+		if err := ix.tmp.Delete(u); err != nil {
+			log.Warn().Err(err).Str("URL", u).Msg("Failed to delete document from the reindexed index")
 		}
-		for _, h := range res.Hits {
-			d := docFromHit(h)
-			log.Debug().Str("URL", d.URL).Msg("Indexing")
-			d.skipSensitiveCheck = skipSensitiveChecks
-			if err := d.Process(); err != nil {
-				if errors.Is(err, ErrSensitiveContent) {
-					log.Warn().Err(err).Str("URL", d.URL).Msg("Skipping document, sensitive content")
-					continue
-				} else if errors.Is(err, ErrNoExtractor) {
-					log.Warn().Err(err).Str("URL", d.URL).Msg("Skipping document, can't extract content")
-					continue
-				} else {
-					tmpIdx.Close()
-					os.RemoveAll(tmpIdxPath)
-					return err
-				}
-			}
-			if rules.IsSkip(d.URL) {
-				log.Info().Str("URL", d.URL).Msg("Dropping URL that has since been added to skip rules.")
-				continue
-			}
-			// priority/score are updated implicitly by bleve
-			if err := tmpIdx.Index(d.URL, d); err != nil {
-				tmpIdx.Close()
-				os.RemoveAll(tmpIdxPath)
-				return err
-			}
-		}
-		page += 1
-		log.Info().Int("Page", page).Msg("Reindexed")
-	}
-	idx.Close()
-	tmpIdx.Close()
-	if err := os.RemoveAll(idxPath); err != nil {
-		return nil
 	}
-	return os.Rename(tmpIdxPath, idxPath)
+	return nil
 }
 
+func (ix *indexer) search(req *bleve.SearchRequest) (*bleve.SearchResult, error) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.idx.Search(req)
+}
+
+func (ix *indexer) docCount() (uint64, error) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.idx.DocCount()
+}
+
+func configure(cfg *config.Config) {
+	configureSensitivePatterns(cfg)
+	urlNormalization = &cfg.URLNormalization
+	querybuilder.URLForms = linkForms
+	querybuilder.IndexedLanguages = indexedLanguages
+	querybuilder.OutgoingLinks = OutgoingLinks
+}
+
+func init() {
+	sanitizer = bluemonday.StrictPolicy()
+}
+
+// Add indexes the document. The content of documents identical
+// to their indexed version isn't extracted again.
 func Add(d *Document) error {
 	if !d.processed {
-		if err := d.Process(); err != nil {
+		if err := d.Prepare(); err != nil {
 			return err
 		}
 	}
-	return i.idx.Index(d.URL, d)
+	annotationMu.Lock()
+	defer annotationMu.Unlock()
+	// annotations may have been updated since the document was prepared
+	if e := getFieldsByURL(d.URL, annotationFields); e != nil {
+		d.copyAnnotations(e)
+		if d.unchanged {
+			d.indexed = e
+		}
+	}
+	updateVisitStats(d)
+	if model.DB == nil {
+		d.LastSeen = time.Now().Unix()
+	}
+	d.keepFirstSeen()
+	if d.upToDate() {
+		log.Debug().Str("URL", d.URL).Msg("Indexed document is up to date")
+		return nil
+	}
+	if err := i.index(d); err != nil {
+		return err
+	}
+	if !d.unchanged {
+		storeRevision(d)
+	}
+	return nil
 }
 
 func Delete(u string) error {
-	return i.idx.Delete(u)
+	if nu := NormalizeURL(u); nu != u {
+		if err := Delete(nu); err != nil {
+			return err
+		}
+	}
+	if err := i.delete(u); err != nil {
+		return err
+	}
+	if model.DB != nil {
+		if err := model.DeleteVisits(u); err != nil {
+			return err
+		}
+		return model.DeleteRevisions(u)
+	}
+	return nil
 }
 
 func Search(cfg *config.Config, q *Query) (*Results, error) {
 	q.cfg = cfg
 	req := bleve.NewSearchRequest(q.create())
-	req.Fields = allFields
+	req.Fields = rankFields
 
+	size := 100
 	if q.Limit > 0 {
-		req.Size = q.Limit
+		size = q.Limit
+	}
+	c := &cursor{Offset: max(q.Offset, 0)}
+	if q.After != "" {
+		var err error
+		if c, err = parseCursor(q.After); err != nil {
+			return nil, err
+		}
+	}
+	// the ID breaks the ties of the sort order to make the cursors unambiguous
+	order := []string{"-_score", "_id"}
+	if q.Sort != "" && q.Sort != "frecency" {
+		var err error
+		if order, err = parseSort(q.Sort); err != nil {
+			return nil, err
+		}
+		order = append(order, "_id")
+	}
+	req.SortBy(order)
+	// hits after the page are fetched to replace the near-duplicates collapsed into it,
+	// one more hit tells whether there are more pages after the window.
+	// Near-duplicates are collapsed only within this window.
+	window := size + collapseWindow
+	if q.Sort == "frecency" {
+		// the top relevance hits are ranked by frecency, so the pages are taken from the same window
+		req.Size = max(c.Offset+window+1, frecencyWindow)
+	} else if after := c.searchAfter(); after != nil {
+		req.Size = window + 1
+		req.SearchAfter = after
 	} else {
-		req.Size = 100
+		req.From = c.Offset
+		req.Size = window + 1
 	}
 
-	switch q.Highlight {
-	case "HTML":
-		req.Highlight = bleve.NewHighlight()
-	case "text":
-		req.Highlight = bleve.NewHighlightWithStyle("ansi")
-	case "tui":
-		req.Highlight = bleve.NewHighlightWithStyle("tui")
-	}
-	switch q.Sort {
-	case "domain":
-		req.SortBy([]string{"domain"})
+	hl := q.highlighter()
+	var dateBuckets []*DateFacet
+	if q.Facets {
+		var err error
+		dateBuckets, err = q.dateBuckets()
+		if err != nil {
+			return nil, err
+		}
+		addFacetRequests(req, dateBuckets)
 	}
-	res, err := i.idx.Search(req)
+	res, err := i.search(req)
 	if err != nil {
 		return nil, err
 	}
-	matches := make([]*Document, len(res.Hits))
-	for j, v := range res.Hits {
+	hits := res.Hits
+	ranked := make([]*Document, len(hits))
+	for j, v := range hits {
 		d := &Document{
-			URL: v.ID,
-		}
-
-		if t, ok := v.Fragments["text"]; ok {
-			d.Text = t[0]
-		}
-		if t, ok := v.Fragments["title"]; ok {
-			d.Title = t[0]
-		} else {
-			s, ok := v.Fields["title"].(string)
-			if ok {
-				d.Title = s
-			}
+			URL:   v.ID,
+			Score: v.Score,
 		}
-		if i, ok := v.Fields["favicon"].(string); ok {
-			d.Favicon = i
+		if s, ok := v.Fields["simhash"].(string); ok {
+			d.Simhash = s
 		}
 		if t, ok := v.Fields["added"].(float64); ok {
 			d.Added = int64(t)
 		}
-		matches[j] = d
+		d.readVisitFields(v)
+		ranked[j] = d
+	}
+	if q.Sort == "frecency" {
+		rankByFrecency(ranked)
+		ranked = ranked[min(c.Offset, len(ranked)):]
+		hits = nil
+	}
+	more := len(ranked) > window
+	if more {
+		ranked = ranked[:window]
+	}
+	page, next, collapsed := collapsePage(ranked, size)
+	matches, err := q.loadDocuments(page, hl)
+	if err != nil {
+		return nil, err
 	}
 	r := &Results{
-		Total:     res.Total,
-		Query:     q,
-		Documents: matches,
+		Total:      res.Total - uint64(collapsed),
+		Offset:     c.Offset,
+		NextOffset: c.Offset + next,
+		HasMore:    next < len(ranked) || more,
+		Query:      q,
+		Documents:  matches,
+	}
+	if r.HasMore {
+		nc := &cursor{Offset: r.NextOffset}
+		if hits != nil {
+			nc = cursorAfter(req.Sort, hits[next-1], r.NextOffset)
+		}
+		r.Next = nc.String()
 	}
-	return r, nil
+	if q.Facets {
+		r.Facets = facetsFromResult(res, dateBuckets)
+	}
+	return correctSpelling(q, r), nil
+}
+
+// loadDocuments fetches the stored fields and the snippets of the ranked documents of a result page.
+// Documents deleted since ranking are left out.
+func (q *Query) loadDocuments(docs []*Document, hl *highlighter) ([]*Document, error) {
+	if len(docs) == 0 {
+		return docs, nil
+	}
+	ids := make([]string, len(docs))
+	for j, d := range docs {
+		ids[j] = d.URL
+	}
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(q.create(), bleve.NewDocIDQuery(ids)), len(ids), 0, false)
+	req.Fields = allFields
+	// match locations are only loaded for the documents of the page, they are used
+	// for highlighting and to find the page or the section of the matches
+	req.IncludeLocations = true
+	res, err := i.search(req)
+	if err != nil {
+		return nil, err
+	}
+	hits := make(map[string]*search.DocumentMatch, len(res.Hits))
+	for _, h := range res.Hits {
+		hits[h.ID] = h
+	}
+	ret := make([]*Document, 0, len(docs))
+	for _, d := range docs {
+		if h, ok := hits[d.URL]; ok {
+			q.readHit(d, h, hl)
+			ret = append(ret, d)
+		}
+	}
+	return ret, nil
+}
+
+// readHit sets the fields and snippets of a search result document
+func (q *Query) readHit(d *Document, v *search.DocumentMatch, hl *highlighter) {
+	if hl != nil {
+		d.Snippets = hl.highlight(v)
+		if hl.num > 1 && hl.formatter != nil {
+			d.Fragments = v.Fragments["text"]
+		}
+	}
+
+	if t, ok := v.Fragments["text"]; ok {
+		d.Text = t[0]
+	}
+	if t, ok := v.Fragments["code"]; ok {
+		d.Code = t[0]
+	}
+	if t, ok := v.Fragments["title"]; ok {
+		d.Title = t[0]
+	} else {
+		s, ok := v.Fields["title"].(string)
+		if ok {
+			d.Title = s
+		}
+	}
+	if i, ok := v.Fields["favicon"].(string); ok {
+		d.Favicon = i
+	}
+	if s, ok := v.Fields["lang"].(string); ok {
+		d.Language = s
+	}
+	d.Tags = readTags(v)
+	if s, ok := v.Fields["note"].(string); ok {
+		d.Note = s
+	}
+	if s, ok := v.Fields["content_type"].(string); ok {
+		d.ContentType = s
+	}
+	if s, ok := v.Fields["author"].(string); ok {
+		d.Author = s
+	}
+	if t, ok := v.Fields["published"].(float64); ok {
+		d.Published = int64(t)
+	}
+	d.readMetadataFields(v)
+	if d.Text == "" {
+		d.Text = d.snippetFallback(v, q.Highlight)
+	}
+	d.Page = pageOfHit(v)
+	if d.Page == 0 && (d.ContentType == "" || d.ContentType == HTMLContentType) && !strings.Contains(d.URL, "#") {
+		d.Anchor = anchorOfHit(v)
+	}
+	d.Meta = readMeta(v)
 }
 
 func GetByURL(u string) *Document {
+	if nu := NormalizeURL(u); nu != u {
+		if d := getByURL(nu); d != nil {
+			return d
+		}
+	}
+	return getByURL(u)
+}
+
+func getByURL(u string) *Document {
+	return getFieldsByURL(u, storedFields)
+}
+
+// getFieldsByURL returns the indexed document of a URL with the given stored fields
+func getFieldsByURL(u string, fields []string) *Document {
 	q := query.NewTermQuery(strings.ToLower(u))
 	q.SetField("url")
 	req := bleve.NewSearchRequest(q)
-	req.Fields = allFields
+	req.Fields = fields
 	req.Highlight = bleve.NewHighlight()
-	res, err := i.idx.Search(req)
+	res, err := i.search(req)
 	if err != nil || len(res.Hits) < 1 {
 		return nil
 	}
@@ -256,10 +492,6 @@ func (d *Document) Process() error {
 	if d.processed {
 		return nil
 	}
-	if !d.skipSensitiveCheck && sensitiveContentRe != nil && sensitiveContentRe.MatchString(d.HTML) {
-		log.Debug().Msg("Matching sensitive content: " + strings.Join(sensitiveContentRe.FindAllString(d.HTML, -1), ","))
-		return ErrSensitiveContent
-	}
 	if d.URL == "" {
 		return errors.New("missing URL")
 	}
@@ -267,53 +499,52 @@ func (d *Document) Process() error {
 	if err != nil {
 		return err
 	}
-	if pu.Scheme == "" || pu.Host == "" {
+	if pu.Scheme == "" || (pu.Host == "" && pu.Scheme != "file") {
 		return errors.New("invalid URL: missing scheme/host")
 	}
-	if pu.Fragment != "" {
-		pu.Fragment = ""
-		d.URL = pu.String()
+	d.decodeHTMLData()
+	if d.Hash == "" {
+		d.Hash = d.sourceHash()
+	}
+	if err := d.checkSensitive(); err != nil {
+		return err
 	}
+	d.URL = NormalizeURL(d.URL)
 	d.Added = time.Now().Unix()
-	q := pu.Query()
-	qChange := false
-	for k := range q {
-		if k == "utm" || strings.HasPrefix(k, "utm_") {
-			qChange = true
-			q.Del(k)
+	if urlNormalization != nil && urlNormalization.UseCanonical && pu.Scheme != "file" {
+		if cu := d.canonicalURL(); cu != "" {
+			d.URL = NormalizeURL(cu)
 		}
 	}
-	if qChange {
-		pu.RawQuery = q.Encode()
-		d.URL = pu.String()
+	if pu, err = url.Parse(d.URL); err != nil {
+		return err
 	}
 	d.Domain = pu.Host
 	if err := d.extractHTML(); err != nil {
 		return err
 	}
+	if d.ContentType == "" && d.isHTML() {
+		d.ContentType = HTMLContentType
+	}
+	// the content of non-HTML documents and the fields of HTML documents
+	// are only available after the extraction
+	if err := d.applySensitivePatterns(); err != nil {
+		return err
+	}
 	d.Title = strings.ReplaceAll(sanitizer.Sanitize(d.Title), "&#34;", `"`)
+	d.Simhash = simhash(d.Text)
+	d.Language = detectLanguage(d.Title + "\n" + d.Text)
 	d.processed = true
 	return nil
 }
 
 func Iterate(fn func(*Document)) {
-	q := query.NewMatchAllQuery()
-	resultNum := 20
-	page := 0
-	for {
-		req := bleve.NewSearchRequest(q)
-		req.Size = resultNum
-		req.From = page * resultNum
-		req.Fields = allFields
-		res, err := i.idx.Search(req)
-		if err != nil || len(res.Hits) < 1 {
-			return
-		}
-		for _, h := range res.Hits {
-			d := docFromHit(h)
-			fn(d)
-		}
-		page += 1
+	err := streamDocuments(i.search, func(d *Document) error {
+		fn(d)
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to iterate documents")
 	}
 }
 
@@ -329,6 +560,8 @@ func docFromHit(h *search.DocumentMatch) *Document {
 	}
 	if t, ok := h.Fragments["text"]; ok {
 		d.Text = t[0]
+	} else if s, ok := h.Fields["text"].(string); ok {
+		d.Text = s
 	}
 	if s, ok := h.Fields["html"].(string); ok {
 		d.HTML = s
@@ -339,9 +572,43 @@ func docFromHit(h *search.DocumentMatch) *Document {
 	if s, ok := h.Fields["domain"].(string); ok {
 		d.Domain = s
 	}
+	if s, ok := h.Fields["simhash"].(string); ok {
+		d.Simhash = s
+	}
+	if s, ok := h.Fields["lang"].(string); ok {
+		d.Language = s
+	}
+	if s, ok := h.Fields["hash"].(string); ok {
+		d.Hash = s
+	}
+	d.Tags = readTags(h)
+	d.Links = readStringList(h, "links")
+	d.Headings = readHeadings(h)
+	if s, ok := h.Fields["note"].(string); ok {
+		d.Note = s
+	}
 	if t, ok := h.Fields["added"].(float64); ok {
 		d.Added = int64(t)
 	}
+	if s, ok := h.Fields["content_type"].(string); ok {
+		d.ContentType = s
+	}
+	if s, ok := h.Fields["data"].(string); ok {
+		d.Data = s
+	}
+	if s, ok := h.Fields["code"].(string); ok {
+		d.Code = s
+	}
+	if s, ok := h.Fields["author"].(string); ok {
+		d.Author = s
+	}
+	if t, ok := h.Fields["published"].(float64); ok {
+		d.Published = int64(t)
+	}
+	d.readMetadataFields(h)
+	d.Page = pageOfHit(h)
+	d.Meta = readMeta(h)
+	d.readVisitFields(h)
 	return d
 }
 
@@ -420,28 +687,89 @@ func createMapping() mapping.IndexMapping {
 		},
 	})
 
+	im.AddCustomTokenizer("comma", map[string]any{
+		"type":   regexpTokenizer.Name,
+		"regexp": `[^,]+`,
+	})
+	im.AddCustomAnalyzer("list", map[string]any{
+		"type":         custom.Name,
+		"char_filters": []string{},
+		"tokenizer":    "comma",
+		"token_filters": []string{
+			"to_lower",
+		},
+	})
+
+	im.AddCustomTokenizer("code", map[string]any{
+		"type":   regexpTokenizer.Name,
+		"regexp": codeTokenRe,
+	})
+	im.AddCustomAnalyzer("code", map[string]any{
+		"type":         custom.Name,
+		"char_filters": []string{},
+		"tokenizer":    "code",
+		"token_filters": []string{
+			"to_lower",
+		},
+	})
+
 	fm := bleve.NewTextFieldMapping()
 	fm.Store = true
 	fm.Index = true
 	fm.IncludeTermVectors = true
 	fm.IncludeInAll = true
 
+	cm := bleve.NewTextFieldMapping()
+	cm.Analyzer = "code"
+	cm.Store = true
+	cm.IncludeTermVectors = true
+	cm.IncludeInAll = false
+
 	um := bleve.NewTextFieldMapping()
 	um.Analyzer = "url"
 
 	noIdxMap := bleve.NewTextFieldMapping()
 	noIdxMap.Index = false
 
+	fields := map[string]*mapping.FieldMapping{
+		"title":        fm,
+		"url":          um,
+		"domain":       um,
+		"text":         fm,
+		"lang":         um,
+		"simhash":      um,
+		"hash":         um,
+		"tags":         um,
+		"links":        um,
+		"note":         fm,
+		"code":         cm,
+		"content_type": um,
+		"author":       fm,
+		"description":  fm,
+		"site_name":    fm,
+		"image":        noIdxMap,
+		"favicon":      noIdxMap,
+		"html":         noIdxMap,
+		"data":         noIdxMap,
+		"added":        bleve.NewNumericFieldMapping(),
+		"visits":       bleve.NewNumericFieldMapping(),
+		"first_seen":   bleve.NewNumericFieldMapping(),
+		"last_seen":    bleve.NewNumericFieldMapping(),
+		"published":    bleve.NewNumericFieldMapping(),
+	}
+
 	docMapping := bleve.NewDocumentMapping()
-	docMapping.AddFieldMappingsAt("title", fm)
-	docMapping.AddFieldMappingsAt("url", um)
-	docMapping.AddFieldMappingsAt("domain", um)
-	docMapping.AddFieldMappingsAt("text", fm)
-	docMapping.AddFieldMappingsAt("favicon", noIdxMap)
-	docMapping.AddFieldMappingsAt("html", noIdxMap)
-	docMapping.AddFieldMappingsAt("added", bleve.NewNumericFieldMapping())
+	for name, m := range fields {
+		docMapping.AddFieldMappingsAt(name, m)
+	}
+	docMapping.AddFieldMappingsAt("title", titleSortMapping())
 
 	im.DefaultMapping = docMapping
+	addLanguageMappings(im, fields)
+	for _, dm := range append([]*mapping.DocumentMapping{docMapping}, slices.Collect(maps.Values(im.TypeMapping))...) {
+		dm.AddSubDocumentMapping("meta", metaMapping())
+		dm.AddSubDocumentMapping("headings", headingsMapping())
+	}
 
 	return im
 }
@@ -490,37 +818,3 @@ func (f *lipglossFormatter) Format(fragment *highlight.Fragment, orderedTermLoca
 
 	return sb.String()
 }
-
-func invertedAnsiHighlighter(config map[string]any, cache *registry.Cache) (highlight.Highlighter, error) {
-	fragmenter, err := cache.FragmenterNamed(simpleFragmenter.Name)
-	if err != nil {
-		return nil, fmt.Errorf("error building fragmenter: %v", err)
-	}
-
-	style := lipgloss.NewStyle().Reverse(true)
-	formatter := newLipglossFormatter(style)
-
-	return simpleHighlighter.NewHighlighter(
-		fragmenter,
-		formatter,
-		simpleHighlighter.DefaultSeparator,
-	), nil
-}
-
-func tuiHighlighter(config map[string]any, cache *registry.Cache) (highlight.Highlighter, error) {
-	fragmenter, err := cache.FragmenterNamed(simpleFragmenter.Name)
-	if err != nil {
-		return nil, fmt.Errorf("error building fragmenter: %v", err)
-	}
-
-	style := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("205")).
-		Bold(true)
-	formatter := newLipglossFormatter(style)
-
-	return simpleHighlighter.NewHighlighter(
-		fragmenter,
-		formatter,
-		simpleHighlighter.DefaultSeparator,
-	), nil
-}
@@ -0,0 +1,86 @@
+package indexer
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/model"
+)
+
+// initTestIndex initializes the index and the database in a temporary data directory
+func initTestIndex(t *testing.T) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HISTER_DATA_DIR", dir)
+	cfg, err := config.Load(filepath.Join(dir, "config.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := i.idx.Close(); err != nil {
+			t.Error(err)
+		}
+		i = nil
+		if db, err := model.DB.DB(); err == nil {
+			db.Close()
+		}
+		model.DB = nil
+	})
+	return cfg
+}
+
+// addTestDocument indexes an HTML document with the given title and text
+func addTestDocument(t *testing.T, u, title, text string) *Document {
+	t.Helper()
+	d := &Document{
+		URL:  u,
+		HTML: "<html><head><title>" + title + "</title></head><body><p>" + text + "</p></body></html>",
+	}
+	if err := Add(d); err != nil {
+		t.Fatalf("Add(%s) error = %v", u, err)
+	}
+	return d
+}
+
+// testPDF returns a minimal PDF file with the given title and a page for each text
+func testPDF(title string, pages ...string) []byte {
+	objs := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Title (%s) >>", title),
+	}
+	kids := ""
+	for _, p := range pages {
+		kids += fmt.Sprintf("%d 0 R ", len(objs)+1)
+		content := fmt.Sprintf("BT /F1 12 Tf 20 200 Td (%s) Tj ET", p)
+		objs = append(objs,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 300 300] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", len(objs)+2),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+	objs[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(pages))
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for j, o := range objs {
+		offsets[j] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", j+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return b.Bytes()
+}
@@ -0,0 +1,230 @@
+package indexer
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/asciimoo/hister/server/indexer/querybuilder"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/cjk"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/da"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/de"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/en"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/es"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/fi"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/fr"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/hu"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/it"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/nl"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/no"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/pt"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/ro"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/ru"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/sv"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/tr"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// languageSampleSize is the number of words/runes inspected during language detection
+	languageSampleSize = 2000
+	// minStopWords is the minimum number of stop words required to detect a language
+	minStopWords = 3
+)
+
+var stopWords map[string]analysis.TokenMap
+
+func init() {
+	cache := registry.NewCache()
+	stopWords = make(map[string]analysis.TokenMap)
+	for _, l := range querybuilder.Languages {
+		if l == "cjk" {
+			continue
+		}
+		tm, err := cache.TokenMapNamed("stop_" + l)
+		if err != nil {
+			log.Warn().Err(err).Str("Language", l).Msg("Failed to load stop words")
+			continue
+		}
+		stopWords[l] = tm
+	}
+}
+
+// detectLanguage returns the ISO 639-1 code of the dominant language of a text
+// or an empty string if the language can't be determined
+func detectLanguage(text string) string {
+	if l := detectCJK(text); l != "" {
+		return l
+	}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) > languageSampleSize {
+		words = words[:languageSampleSize]
+	}
+	counts := make(map[string]int)
+	for _, w := range words {
+		for l, tm := range stopWords {
+			if tm[w] {
+				counts[l]++
+			}
+		}
+	}
+	lang := ""
+	best := minStopWords - 1
+	for _, l := range querybuilder.Languages {
+		if counts[l] > best {
+			lang = l
+			best = counts[l]
+		}
+	}
+	return lang
+}
+
+// detectCJK returns zh, ja or ko if most of the letters of the text are CJK characters
+func detectCJK(text string) string {
+	var letters, han, kana, hangul int
+	n := 0
+	for _, r := range text {
+		if n >= languageSampleSize {
+			break
+		}
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		n++
+		letters++
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		}
+	}
+	cjk := han + kana + hangul
+	if letters == 0 || cjk*10 < letters*3 {
+		return ""
+	}
+	switch {
+	case hangul >= han && hangul >= kana:
+		return "ko"
+	case kana*20 >= cjk:
+		return "ja"
+	default:
+		return "zh"
+	}
+}
+
+// languageAnalyzer returns the name of the analyzer of a language
+func languageAnalyzer(lang string) string {
+	switch lang {
+	case "zh", "ja", "ko":
+		return "cjk"
+	}
+	for _, l := range querybuilder.Languages {
+		if l == lang {
+			return l
+		}
+	}
+	return ""
+}
+
+// BleveType selects the language specific document mapping
+func (d *Document) BleveType() string {
+	return languageAnalyzer(d.Language)
+}
+
+// addLanguageMappings registers a document mapping for each supported language
+// which indexes title and text additionally with the language's analyzer
+// to title_<lang> and text_<lang> fields
+func addLanguageMappings(im *mapping.IndexMappingImpl, fields map[string]*mapping.FieldMapping) {
+	for _, l := range querybuilder.Languages {
+		dm := bleve.NewDocumentMapping()
+		for name, fm := range fields {
+			if name != "title" && name != "text" {
+				dm.AddFieldMappingsAt(name, fm)
+			}
+		}
+		for _, f := range []string{"title", "text"} {
+			lm := bleve.NewTextFieldMapping()
+			lm.Name = f + "_" + l
+			lm.Analyzer = l
+			lm.Store = false
+			lm.IncludeInAll = false
+			lm.IncludeTermVectors = true
+			fms := []*mapping.FieldMapping{fields[f], lm}
+			if f == "title" {
+				fms = append(fms, titleSortMapping())
+			}
+			dm.AddFieldMappingsAt(f, fms...)
+		}
+		im.AddDocumentMapping(l, dm)
+	}
+}
+
+// loadLanguages collects the languages of the documents of the live index
+func (ix *indexer) loadLanguages() error {
+	dict, err := ix.idx.FieldDict("lang")
+	if err != nil {
+		return err
+	}
+	defer dict.Close()
+	langs := make(map[string]bool)
+	for {
+		e, err := dict.Next()
+		if err != nil {
+			return err
+		}
+		if e == nil {
+			break
+		}
+		if a := languageAnalyzer(e.Term); a != "" {
+			langs[a] = true
+		}
+	}
+	ix.langMu.Lock()
+	ix.langs = langs
+	ix.langMu.Unlock()
+	return nil
+}
+
+func (ix *indexer) addLanguage(lang string) {
+	a := languageAnalyzer(lang)
+	if a == "" {
+		return
+	}
+	ix.langMu.RLock()
+	known := ix.langs[a]
+	ix.langMu.RUnlock()
+	if known {
+		return
+	}
+	ix.langMu.Lock()
+	if ix.langs == nil {
+		ix.langs = make(map[string]bool)
+	}
+	ix.langs[a] = true
+	ix.langMu.Unlock()
+}
+
+// indexedLanguages returns the analyzers of the languages present in the index
+func indexedLanguages() []string {
+	if i == nil {
+		return querybuilder.Languages
+	}
+	i.langMu.RLock()
+	defer i.langMu.RUnlock()
+	var ret []string
+	for _, l := range querybuilder.Languages {
+		if i.langs[l] {
+			ret = append(ret, l)
+		}
+	}
+	return ret
+}
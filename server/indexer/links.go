@@ -0,0 +1,139 @@
+package indexer
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/asciimoo/hister/server/indexer/querybuilder"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// maxLinks is the maximum number of outgoing links stored per document
+const maxLinks = 1000
+
+// maxBacklinks is the maximum number of documents returned by Backlinks
+const maxBacklinks = 100
+
+var mdURLRe = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)|<(https?://[^>\s]+)>`)
+
+// Backlink is an indexed document linking to another document
+type Backlink struct {
+	URL      string `json:"url"`
+	Title    string `json:"title"`
+	Favicon  string `json:"favicon,omitempty"`
+	Visits   int    `json:"visits"`
+	LastSeen int64  `json:"last_seen"`
+}
+
+// extractLinks collects the outgoing links of HTML documents
+func (d *Document) extractLinks() {
+	if d.HTML == "" {
+		return
+	}
+	doc, err := parseDocument(d)
+	if err != nil {
+		return
+	}
+	hrefs := make([]string, 0)
+	for _, n := range selectAll(doc, "a[href]") {
+		hrefs = append(hrefs, attr(n, "href"))
+	}
+	d.setLinks(hrefs)
+}
+
+// markdownLinks returns the targets of inline and autolinks of a Markdown document
+func markdownLinks(s string) []string {
+	var ret []string
+	for _, m := range mdURLRe.FindAllStringSubmatch(s, -1) {
+		ret = append(ret, m[1]+m[2])
+	}
+	return ret
+}
+
+// setLinks stores the normalized absolute form of the given link targets,
+// links to the document itself are dropped
+func (d *Document) setLinks(hrefs []string) {
+	base, err := url.Parse(d.URL)
+	if err != nil {
+		return
+	}
+	self := linkKey(base.String())
+	seen := make(map[string]bool)
+	d.Links = nil
+	for _, h := range hrefs {
+		h = strings.TrimSpace(h)
+		if h == "" || strings.HasPrefix(h, "#") {
+			continue
+		}
+		lu, err := base.Parse(h)
+		if err != nil || (lu.Scheme != "http" && lu.Scheme != "https" && lu.Scheme != "file") {
+			continue
+		}
+		lu.Fragment = ""
+		lu.RawFragment = ""
+		l := NormalizeURL(lu.String())
+		k := linkKey(l)
+		if k == self || seen[k] {
+			continue
+		}
+		seen[k] = true
+		d.Links = append(d.Links, l)
+		if len(d.Links) == maxLinks {
+			return
+		}
+	}
+}
+
+func linkKey(u string) string {
+	return strings.ToLower(strings.TrimSuffix(u, "/"))
+}
+
+// OutgoingLinks returns the links of the indexed document of a URL
+func OutgoingLinks(u string) []string {
+	d := GetByURL(u)
+	if d == nil {
+		return nil
+	}
+	return d.Links
+}
+
+// linkForms returns the submitted and the normalized form of a URL
+// with and without trailing slash, as links are deduplicated regardless of it
+func linkForms(u string) []string {
+	var forms []string
+	for _, f := range []string{u, NormalizeURL(u)} {
+		f = strings.TrimSuffix(f, "/")
+		for _, v := range []string{f, f + "/"} {
+			if !slices.Contains(forms, v) {
+				forms = append(forms, v)
+			}
+		}
+	}
+	return forms
+}
+
+// Backlinks returns the indexed documents linking to the URL, most recently seen first
+func Backlinks(u string) ([]*Backlink, error) {
+	req := bleve.NewSearchRequestOptions(querybuilder.LinksQuery(u), maxBacklinks, 0, false)
+	req.Fields = []string{"url", "title", "favicon", "visits", "last_seen", "added"}
+	req.SortBy([]string{"-last_seen", "-added"})
+	res, err := i.search(req)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*Backlink, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		d := docFromHit(h)
+		ret = append(ret, &Backlink{
+			URL:      d.URL,
+			Title:    d.Title,
+			Favicon:  d.Favicon,
+			Visits:   d.Visits,
+			LastSeen: max(d.LastSeen, d.Added),
+		})
+	}
+	return ret, nil
+}
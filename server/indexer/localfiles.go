@@ -0,0 +1,351 @@
+package indexer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/model"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxFileSize is the size limit of indexed local and uploaded files
+const MaxFileSize = 32 << 20
+
+// watchDelay is the time waited for further changes of a modified file before indexing it
+const watchDelay = 500 * time.Millisecond
+
+// DirectoryStats summarizes the changes made by indexing a local directory
+type DirectoryStats struct {
+	Indexed   int `json:"indexed"`
+	Unchanged int `json:"unchanged"`
+	Deleted   int `json:"deleted"`
+	Failed    int `json:"failed"`
+}
+
+// FileURL returns the file:// URL of a local path
+func FileURL(p string) string {
+	u := &url.URL{
+		Scheme: "file",
+		Path:   filepath.ToSlash(p),
+	}
+	return u.String()
+}
+
+func relPath(dir *config.Directory, p string) string {
+	rel, err := filepath.Rel(dir.Path, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// indexable reports whether a local file is indexed as part of the directory
+func indexable(dir *config.Directory, p string) bool {
+	return ContentTypeByName(p) != "" && dir.Included(relPath(dir, p))
+}
+
+// IndexDirectory indexes the new and modified files of a local directory
+// and removes the deleted or excluded files from the index.
+// Files are compared to the previous run by their modification time and size.
+func IndexDirectory(dir *config.Directory) (*DirectoryStats, error) {
+	return indexDirectory(dir, dir.Path)
+}
+
+// indexDirectory synchronizes the files of root which is the configured directory or one of its subdirectories
+func indexDirectory(dir *config.Directory, root string) (*DirectoryStats, error) {
+	if model.DB == nil {
+		return nil, errors.New("database is not initialized")
+	}
+	known, err := model.GetLocalFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	s := &DirectoryStats{}
+	seen := make(map[string]bool)
+	err = filepath.WalkDir(root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			log.Warn().Err(err).Str("path", p).Msg("Failed to read directory entry")
+			return nil
+		}
+		rel := relPath(dir, p)
+		if e.IsDir() {
+			if rel != "." && dir.Excluded(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !e.Type().IsRegular() || !indexable(dir, p) {
+			return nil
+		}
+		seen[p] = true
+		info, err := e.Info()
+		if err != nil {
+			s.Failed++
+			return nil
+		}
+		if f, ok := known[p]; ok && !fileChanged(f, info) {
+			s.Unchanged++
+			return nil
+		}
+		if err := indexFile(p, info); err != nil {
+			log.Warn().Err(err).Str("path", p).Msg("Failed to index file")
+			s.Failed++
+			return nil
+		}
+		s.Indexed++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for p := range known {
+		if seen[p] {
+			continue
+		}
+		if err := deleteFile(p); err != nil {
+			log.Warn().Err(err).Str("path", p).Msg("Failed to delete file from the index")
+			s.Failed++
+			continue
+		}
+		s.Deleted++
+	}
+	return s, nil
+}
+
+func fileChanged(f *model.LocalFile, info fs.FileInfo) bool {
+	return f.ModTime != info.ModTime().UnixNano() || f.Size != info.Size()
+}
+
+func indexFile(p string, info fs.FileInfo) error {
+	if info.Size() > MaxFileSize {
+		return fmt.Errorf("file is larger than %d bytes", MaxFileSize)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	d := &Document{
+		URL:         FileURL(p),
+		ContentType: ContentTypeByName(p),
+	}
+	if d.ContentType == HTMLContentType {
+		d.HTML = string(b)
+	} else {
+		d.Data = base64.StdEncoding.EncodeToString(b)
+	}
+	if err := Add(d); err != nil {
+		return err
+	}
+	log.Debug().Str("path", p).Msg("File indexed")
+	return model.SaveLocalFile(p, info.ModTime().UnixNano(), info.Size())
+}
+
+func deleteFile(p string) error {
+	if err := Delete(FileURL(p)); err != nil {
+		return err
+	}
+	log.Debug().Str("path", p).Msg("File removed from the index")
+	return model.DeleteLocalFile(p)
+}
+
+// syncPath updates the index after a change of a file or a directory
+func syncPath(dir *config.Directory, p string) {
+	info, err := os.Stat(p)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Warn().Err(err).Str("path", p).Msg("Failed to read file")
+			return
+		}
+		// a removed file or all the files of a removed directory
+		known, err := model.GetLocalFiles(p)
+		if err != nil {
+			log.Warn().Err(err).Str("path", p).Msg("Failed to get indexed files")
+			return
+		}
+		if f, err := model.GetLocalFile(p); err == nil && f != nil {
+			known[p] = f
+		}
+		for fp := range known {
+			if err := deleteFile(fp); err != nil {
+				log.Warn().Err(err).Str("path", fp).Msg("Failed to delete file from the index")
+			}
+		}
+		return
+	}
+	if info.IsDir() {
+		// directories moved into the watched directory
+		if _, err := indexDirectory(dir, p); err != nil {
+			log.Warn().Err(err).Str("path", p).Msg("Failed to index directory")
+		}
+		return
+	}
+	if !info.Mode().IsRegular() {
+		return
+	}
+	f, err := model.GetLocalFile(p)
+	if err != nil {
+		log.Warn().Err(err).Str("path", p).Msg("Failed to get indexed file")
+		return
+	}
+	if !indexable(dir, p) {
+		if f != nil {
+			if err := deleteFile(p); err != nil {
+				log.Warn().Err(err).Str("path", p).Msg("Failed to delete file from the index")
+			}
+		}
+		return
+	}
+	if f != nil && !fileChanged(f, info) {
+		return
+	}
+	if err := indexFile(p, info); err != nil {
+		log.Warn().Err(err).Str("path", p).Msg("Failed to index file")
+	}
+}
+
+type dirWatcher struct {
+	w       *fsnotify.Watcher
+	dirs    []*config.Directory
+	mu      sync.Mutex
+	syncMu  sync.Mutex
+	pending map[string]*config.Directory
+	timer   *time.Timer
+}
+
+// WatchDirectories indexes the directories configured to be watched
+// and keeps the index in sync with their changes in the background.
+func WatchDirectories(dirs []*config.Directory) error {
+	dw := &dirWatcher{
+		pending: make(map[string]*config.Directory),
+	}
+	for _, d := range dirs {
+		if d.Watch {
+			dw.dirs = append(dw.dirs, d)
+		}
+	}
+	if len(dw.dirs) == 0 {
+		return nil
+	}
+	var err error
+	dw.w, err = fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, d := range dw.dirs {
+		if err := dw.add(d, d.Path); err != nil {
+			dw.w.Close()
+			return err
+		}
+	}
+	go dw.run()
+	return nil
+}
+
+// add watches a directory and its subdirectories
+func (dw *dirWatcher) add(dir *config.Directory, root string) error {
+	return filepath.WalkDir(root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if !e.IsDir() {
+			return nil
+		}
+		if rel := relPath(dir, p); rel != "." && dir.Excluded(rel) {
+			return filepath.SkipDir
+		}
+		return dw.w.Add(p)
+	})
+}
+
+// directory returns the innermost watched directory containing the path
+func (dw *dirWatcher) directory(p string) *config.Directory {
+	var ret *config.Directory
+	for _, d := range dw.dirs {
+		if (p == d.Path || strings.HasPrefix(p, d.Path+string(filepath.Separator))) && (ret == nil || len(d.Path) > len(ret.Path)) {
+			ret = d
+		}
+	}
+	return ret
+}
+
+func (dw *dirWatcher) run() {
+	for _, d := range dw.dirs {
+		s, err := IndexDirectory(d)
+		if err != nil {
+			log.Warn().Err(err).Str("directory", d.Path).Msg("Failed to index directory")
+			continue
+		}
+		log.Info().Str("directory", d.Path).Int("indexed", s.Indexed).Int("deleted", s.Deleted).Msg("Directory synchronized")
+	}
+	for {
+		select {
+		case e, ok := <-dw.w.Events:
+			if !ok {
+				return
+			}
+			dw.handle(e)
+		case err, ok := <-dw.w.Errors:
+			if !ok {
+				return
+			}
+			log.Warn().Err(err).Msg("Directory watcher error")
+		}
+	}
+}
+
+func (dw *dirWatcher) handle(e fsnotify.Event) {
+	if e.Has(fsnotify.Chmod) && !e.Has(fsnotify.Write) {
+		return
+	}
+	d := dw.directory(e.Name)
+	if d == nil {
+		return
+	}
+	if rel := relPath(d, e.Name); rel != "." && d.Excluded(rel) {
+		return
+	}
+	if e.Has(fsnotify.Create) {
+		if info, err := os.Stat(e.Name); err == nil && info.IsDir() {
+			if err := dw.add(d, e.Name); err != nil {
+				log.Warn().Err(err).Str("path", e.Name).Msg("Failed to watch directory")
+			}
+		}
+	}
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	dw.pending[e.Name] = d
+	if dw.timer == nil {
+		dw.timer = time.AfterFunc(watchDelay, dw.flush)
+	} else {
+		dw.timer.Reset(watchDelay)
+	}
+}
+
+func (dw *dirWatcher) flush() {
+	dw.syncMu.Lock()
+	defer dw.syncMu.Unlock()
+	dw.mu.Lock()
+	pending := dw.pending
+	dw.pending = make(map[string]*config.Directory)
+	dw.mu.Unlock()
+	for p, d := range pending {
+		syncPath(d, p)
+	}
+}
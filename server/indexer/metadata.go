@@ -0,0 +1,223 @@
+package indexer
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"github.com/blevesearch/bleve/v2/search"
+	"golang.org/x/net/html"
+)
+
+// articleTypes are the schema.org types read from JSON-LD metadata
+var articleTypes = []string{"Article", "NewsArticle", "BlogPosting", "TechArticle", "ScholarlyArticle", "Report", "SocialMediaPosting", "WebPage"}
+
+// pageMetadata holds the metadata found in the head of an HTML document
+type pageMetadata struct {
+	meta   map[string]string
+	ldJSON []map[string]any
+}
+
+// extractMetadata fills the empty metadata fields of an HTML document
+// from its meta tags, OpenGraph and Twitter card properties and JSON-LD data.
+// Fields already set by the content extractors are kept.
+func (d *Document) extractMetadata() {
+	if d.HTML == "" {
+		return
+	}
+	doc, err := parseDocument(d)
+	if err != nil {
+		return
+	}
+	pm := readPageMetadata(doc)
+	article := pm.article()
+	if d.Description == "" {
+		d.Description = firstNonEmpty(pm.meta["description"], pm.meta["og:description"], pm.meta["twitter:description"], ldString(article["description"]))
+	}
+	if d.Author == "" {
+		d.Author = firstNonEmpty(ldName(article["author"]), pm.meta["author"], pm.meta["article:author"], pm.meta["twitter:creator"])
+	}
+	if d.SiteName == "" {
+		d.SiteName = firstNonEmpty(pm.meta["og:site_name"], ldName(article["publisher"]), pm.meta["application-name"])
+	}
+	if d.Image == "" {
+		if img := firstNonEmpty(pm.meta["og:image"], pm.meta["twitter:image"], ldImage(article["image"])); img != "" {
+			d.Image = fullURL(d.URL, img)
+		}
+	}
+	if d.Published == 0 {
+		d.Published = parseDate(firstNonEmpty(pm.meta["article:published_time"], ldString(article["datePublished"]), pm.meta["og:published_time"], pm.meta["date"], pm.meta["dc.date"], pm.meta["citation_publication_date"]))
+	}
+	if strings.TrimSpace(d.Title) == "" {
+		d.Title = firstNonEmpty(pm.meta["og:title"], pm.meta["twitter:title"], ldString(article["headline"]))
+	}
+	d.Description = strings.Join(strings.Fields(d.Description), " ")
+}
+
+// Byline returns the author and the publication date of a document
+func (d *Document) Byline() string {
+	parts := make([]string, 0, 2)
+	if d.Author != "" {
+		parts = append(parts, "by "+d.Author)
+	}
+	if d.Published != 0 {
+		parts = append(parts, time.Unix(d.Published, 0).Format("2006-01-02"))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (d *Document) readMetadataFields(h *search.DocumentMatch) {
+	if s, ok := h.Fields["description"].(string); ok {
+		d.Description = s
+	}
+	if s, ok := h.Fields["site_name"].(string); ok {
+		d.SiteName = s
+	}
+	if s, ok := h.Fields["image"].(string); ok {
+		d.Image = s
+	}
+}
+
+// snippetFallback returns the description of a result as snippet if its text has no highlighted fragment
+func (d *Document) snippetFallback(h *search.DocumentMatch, highlight string) string {
+	if f, ok := h.Fragments["description"]; ok {
+		return f[0]
+	}
+	if highlight == "HTML" {
+		return html.EscapeString(d.Description)
+	}
+	return d.Description
+}
+
+func readPageMetadata(doc *html.Node) *pageMetadata {
+	pm := &pageMetadata{
+		meta: make(map[string]string),
+	}
+	for _, n := range selectAll(doc, "meta") {
+		k := strings.ToLower(firstNonEmpty(attr(n, "property"), attr(n, "name"), attr(n, "itemprop")))
+		v := attr(n, "content")
+		if k == "" || v == "" {
+			continue
+		}
+		if _, ok := pm.meta[k]; !ok {
+			pm.meta[k] = v
+		}
+	}
+	for _, n := range selectAll(doc, `script[type="application/ld+json"]`) {
+		if n.FirstChild == nil {
+			continue
+		}
+		var v any
+		if err := json.Unmarshal([]byte(n.FirstChild.Data), &v); err != nil {
+			continue
+		}
+		pm.ldJSON = append(pm.ldJSON, ldObjects(v)...)
+	}
+	return pm
+}
+
+// ldObjects flattens JSON-LD arrays and graphs
+func ldObjects(v any) []map[string]any {
+	switch o := v.(type) {
+	case []any:
+		var ret []map[string]any
+		for _, e := range o {
+			ret = append(ret, ldObjects(e)...)
+		}
+		return ret
+	case map[string]any:
+		if g, ok := o["@graph"]; ok {
+			return ldObjects(g)
+		}
+		return []map[string]any{o}
+	}
+	return nil
+}
+
+// article returns the first JSON-LD object having an article type
+func (pm *pageMetadata) article() map[string]any {
+	for _, t := range articleTypes {
+		for _, o := range pm.ldJSON {
+			if ldHasType(o, t) {
+				return o
+			}
+		}
+	}
+	return nil
+}
+
+func ldHasType(o map[string]any, t string) bool {
+	switch v := o["@type"].(type) {
+	case string:
+		return v == t
+	case []any:
+		for _, e := range v {
+			if s, ok := e.(string); ok && s == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ldString(v any) string {
+	if s, ok := v.(string); ok {
+		return strings.TrimSpace(s)
+	}
+	return ""
+}
+
+// ldName returns the name of a person or organization or the names of a list of them
+func ldName(v any) string {
+	switch o := v.(type) {
+	case string:
+		return strings.TrimSpace(o)
+	case map[string]any:
+		return ldString(o["name"])
+	case []any:
+		names := make([]string, 0, len(o))
+		for _, e := range o {
+			if n := ldName(e); n != "" {
+				names = append(names, n)
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+func ldImage(v any) string {
+	switch o := v.(type) {
+	case string:
+		return o
+	case map[string]any:
+		return ldString(o["url"])
+	case []any:
+		if len(o) > 0 {
+			return ldImage(o[0])
+		}
+	}
+	return ""
+}
+
+func firstNonEmpty(vs ...string) string {
+	for _, v := range vs {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseDate returns the unix timestamp of a date in any common format or 0 if it can't be parsed
+func parseDate(s string) int64 {
+	if s == "" {
+		return 0
+	}
+	t, err := dateparse.ParseAny(s)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
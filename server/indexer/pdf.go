@@ -0,0 +1,133 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/ledongthuc/pdf"
+)
+
+const PDFContentType = "application/pdf"
+
+// pageSeparator separates the text of the pages of paged documents
+const pageSeparator = "\f"
+
+type pdfExtractor struct{}
+
+// IsPDF reports whether the submitted content of the document is a PDF file
+func (d *Document) IsPDF() bool {
+	if strings.HasPrefix(d.ContentType, PDFContentType) {
+		return true
+	}
+	// "%PDF-" magic bytes
+	return d.ContentType == "" && strings.HasPrefix(d.Data, "JVBERi0")
+}
+
+// Link returns the URL of the document pointing to the page or the section of the best match if it is known
+func (d *Document) Link() string {
+	if d.Page > 0 {
+		return d.URL + "#page=" + strconv.Itoa(d.Page)
+	}
+	if d.Anchor != "" && !strings.Contains(d.URL, "#") {
+		return d.URL + "#" + d.Anchor
+	}
+	return d.URL
+}
+
+func (e *pdfExtractor) Name() string {
+	return "PDF"
+}
+
+func (e *pdfExtractor) Match(d *Document) bool {
+	return d.IsPDF()
+}
+
+func (e *pdfExtractor) Extract(d *Document) (err error) {
+	if d.Data == "" && d.Text != "" {
+		// the PDF file isn't stored if it contained sensitive content, keep the extracted content
+		d.ContentType = PDFContentType
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(d.Data)
+	if err != nil {
+		return errors.New("invalid PDF data encoding: " + err.Error())
+	}
+	// the PDF parser panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to parse PDF: %v", r)
+		}
+	}()
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	n := r.NumPage()
+	pages := make([]string, 0, n)
+	for p := 1; p <= n; p++ {
+		t, err := r.Page(p).GetPlainText(nil)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, strings.TrimSpace(strings.ReplaceAll(t, pageSeparator, " ")))
+	}
+	info := r.Trailer().Key("Info")
+	d.ContentType = PDFContentType
+	d.Text = strings.Join(pages, pageSeparator)
+	d.Title = strings.TrimSpace(info.Key("Title").Text())
+	d.Author = strings.TrimSpace(info.Key("Author").Text())
+	d.Published = parsePDFDate(info.Key("CreationDate").Text())
+	if d.Title == "" {
+		if pu, err := url.Parse(d.URL); err == nil {
+			d.Title = path.Base(pu.Path)
+		}
+	}
+	if strings.TrimSpace(d.Text) == "" && d.Title == "" {
+		return errors.New("no content found")
+	}
+	return nil
+}
+
+// parsePDFDate converts PDF date strings like "D:20240131154500+01'00'" to unix timestamps
+func parsePDFDate(s string) int64 {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "D:")
+	digits := 0
+	for digits < len(s) && digits < 14 && s[digits] >= '0' && s[digits] <= '9' {
+		digits++
+	}
+	if digits < 4 || digits%2 != 0 {
+		return 0
+	}
+	layout := "20060102150405"[:digits]
+	value := s[:digits]
+	if tz := strings.ReplaceAll(s[digits:], "'", ""); digits == 14 && len(tz) == 5 && (tz[0] == '+' || tz[0] == '-') {
+		layout += "-0700"
+		value += tz
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+// pageOfHit returns the page of the first text match of a paged document
+func pageOfHit(h *search.DocumentMatch) int {
+	text, ok := h.Fields["text"].(string)
+	if !ok || !strings.Contains(text, pageSeparator) {
+		return 0
+	}
+	start, _, ok := firstTextMatch(h)
+	if !ok || start > len(text) {
+		return 0
+	}
+	return strings.Count(text[:start], pageSeparator) + 1
+}
@@ -0,0 +1,109 @@
+package indexer
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/search"
+)
+
+func TestPDFExtractor(t *testing.T) {
+	d := &Document{
+		URL:  "https://example.com/report.pdf",
+		Data: base64.StdEncoding.EncodeToString(testPDF("Report", "first page", "second page")),
+	}
+	e := &pdfExtractor{}
+	if !e.Match(d) {
+		t.Fatal("PDF data without content type doesn't match")
+	}
+	if err := e.Extract(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.ContentType != PDFContentType {
+		t.Errorf("content type = %q, want %q", d.ContentType, PDFContentType)
+	}
+	if d.Title != "Report" {
+		t.Errorf("title = %q, want Report", d.Title)
+	}
+	if d.Text != "first page"+pageSeparator+"second page" {
+		t.Errorf("text = %q, want the pages separated by %q", d.Text, pageSeparator)
+	}
+
+	d = &Document{
+		URL:  "https://example.com/untitled.pdf",
+		Data: base64.StdEncoding.EncodeToString(testPDF("", "content")),
+	}
+	if err := e.Extract(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "untitled.pdf" {
+		t.Errorf("title of untitled PDF = %q, want the file name", d.Title)
+	}
+
+	for _, data := range []string{"invalid base64", base64.StdEncoding.EncodeToString([]byte("%PDF-1.4 truncated"))} {
+		d = &Document{URL: "https://example.com/broken.pdf", ContentType: PDFContentType, Data: data}
+		if err := e.Extract(d); err == nil {
+			t.Errorf("Extract(%q) error = nil, want error", data)
+		}
+	}
+}
+
+func TestPageOfHit(t *testing.T) {
+	text := "one" + pageSeparator + "two" + pageSeparator + "three"
+	loc := func(field string, start uint64) search.FieldTermLocationMap {
+		return search.FieldTermLocationMap{
+			field: search.TermLocationMap{"term": search.Locations{{Start: start, End: start + 3}}},
+		}
+	}
+	tests := []struct {
+		name string
+		text string
+		locs search.FieldTermLocationMap
+		page int
+	}{
+		{name: "first page", text: text, locs: loc("text", 0), page: 1},
+		{name: "second page", text: text, locs: loc("text", 4), page: 2},
+		{name: "third page", text: text, locs: loc("text", 8), page: 3},
+		{name: "language specific field", text: text, locs: loc("text_en", 8), page: 3},
+		{name: "no text match", text: text, locs: loc("title", 0)},
+		{name: "match out of the text", text: text, locs: loc("text", 100)},
+		{name: "single page", text: "one two", locs: loc("text", 4)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &search.DocumentMatch{
+				Fields:    map[string]any{"text": tc.text},
+				Locations: tc.locs,
+			}
+			if p := pageOfHit(h); p != tc.page {
+				t.Errorf("pageOfHit() = %d, want %d", p, tc.page)
+			}
+		})
+	}
+}
+
+func TestSearchPDFPage(t *testing.T) {
+	cfg := initTestIndex(t)
+	d := &Document{
+		URL:         "https://example.com/report.pdf",
+		ContentType: PDFContentType,
+		Data:        base64.StdEncoding.EncodeToString(testPDF("Report", "introduction", "conclusion")),
+	}
+	if err := Add(d); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Search(cfg, &Query{Text: "conclusion"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Documents) != 1 {
+		t.Fatalf("found %d documents, want 1", len(r.Documents))
+	}
+	if p := r.Documents[0].Page; p != 2 {
+		t.Errorf("page = %d, want 2", p)
+	}
+	if l := r.Documents[0].Link(); !strings.HasSuffix(l, "#page=2") {
+		t.Errorf("link = %q, want a link to the second page", l)
+	}
+}
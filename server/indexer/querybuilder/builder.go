@@ -1,6 +1,7 @@
 package querybuilder
 
 import (
+	"slices"
 	"strings"
 
 	"github.com/blevesearch/bleve/v2"
@@ -12,8 +13,74 @@ var weights = map[string]float64{
 	"url":    4,
 	"domain": 8,
 	"title":  12,
+	"lang":   1,
+	"tags":   10,
+	"note":   6,
+
+	"author":      6,
+	"description": 3,
+	"site_name":   4,
+	"published":   1,
+
+	"content_type": 1,
+	"links":        1,
+
+	"headings.text": 4,
+	"code":          2,
+
+	"meta.repo":      8,
+	"meta.issue":     4,
+	"meta.subreddit": 6,
+	"meta.accepted":  1,
+	"meta.state":     1,
+	"meta.kind":      1,
+	"meta.topics":    4,
+}
+
+// operators maps query operators to index fields if their names differ
+var operators = map[string]string{
+	"tag":        "tags",
+	"repo":       "meta.repo",
+	"issue":      "meta.issue",
+	"subreddit":  "meta.subreddit",
+	"accepted":   "meta.accepted",
+	"state":      "meta.state",
+	"kind":       "meta.kind",
+	"topic":      "meta.topics",
+	"type":       "content_type",
+	"linkedfrom": "linkedfrom",
+	"heading":    "headings.text",
+}
+
+// contentTypes maps the values of the type operator to content types
+var contentTypes = map[string]string{
+	"html":     "text/html",
+	"pdf":      "application/pdf",
+	"text":     "text/plain",
+	"txt":      "text/plain",
+	"markdown": "text/markdown",
+	"md":       "text/markdown",
+	"json":     "application/json",
+	"code":     "text/x-*",
+}
+
+// keywordFields are matched by exact, case insensitive terms
+var keywordFields = []string{"url", "domain", "lang", "tags", "content_type", "links"}
+
+// Languages are the analyzers having dedicated title_<lang> and text_<lang> fields in the index
+var Languages = []string{"en", "de", "fr", "es", "it", "pt", "nl", "sv", "da", "no", "fi", "hu", "ro", "ru", "tr", "cjk"}
+
+// IndexedLanguages returns the Languages of the indexed documents,
+// only their language analyzed fields are searched.
+// It is replaced by the indexer.
+var IndexedLanguages = func() []string {
+	return Languages
 }
 
+// languageWeight is the boost multiplier of the language analyzed fields
+// compared to the exact title and text fields
+const languageWeight = 0.5
+
 func Build(s string) query.Query {
 	if strings.TrimSpace(s) == "" {
 		return query.NewMatchNoneQuery()
@@ -38,6 +105,19 @@ func Build(s string) query.Query {
 	return query.NewBooleanQuery(qs, nil, nqs)
 }
 
+// contentType returns the content type of a type operator value.
+// Unknown names are treated as programming languages.
+func contentType(v string) string {
+	v = strings.ToLower(v)
+	if ct, ok := contentTypes[v]; ok {
+		return ct
+	}
+	if strings.Contains(v, "/") || strings.Contains(v, "*") {
+		return v
+	}
+	return "text/x-" + v
+}
+
 func createSimpleQuery(s string) query.Query {
 	return bleve.NewQueryStringQuery(s)
 }
@@ -46,37 +126,80 @@ func getTokenQuery(t Token) (query.Query, bool) {
 	negated := false
 	switch t.Type {
 	case TokenQuoted:
-		titleq := bleve.NewPhraseQuery(strings.Fields(t.Value), "title")
-		titleq.SetBoost(weights["title"])
-		textq := bleve.NewPhraseQuery(strings.Fields(t.Value), "text")
-		textq.SetBoost(weights["text"])
-		return bleve.NewDisjunctionQuery(titleq, textq), negated
+		langs := IndexedLanguages()
+		qs := []query.Query{}
+		for _, f := range []string{"title", "text"} {
+			pq := bleve.NewMatchPhraseQuery(t.Value)
+			pq.SetField(f)
+			pq.SetBoost(weights[f])
+			qs = append(qs, pq)
+			for _, l := range langs {
+				q := bleve.NewMatchPhraseQuery(t.Value)
+				q.SetField(f + "_" + l)
+				q.SetBoost(weights[f] * languageWeight)
+				qs = append(qs, q)
+			}
+		}
+		for _, f := range []string{"note", "description", "headings.text"} {
+			pq := bleve.NewMatchPhraseQuery(t.Value)
+			pq.SetField(f)
+			pq.SetBoost(weights[f])
+			qs = append(qs, pq)
+		}
+		return bleve.NewDisjunctionQuery(qs...), negated
 	case TokenWord:
-		var field string
+		var field, op string
 		for f := range weights {
 			if strings.HasPrefix(t.Value, f+":") {
-				field = f
+				field, op = f, f
+				break
+			}
+		}
+		for o, f := range operators {
+			if strings.HasPrefix(t.Value, o+":") {
+				field, op = f, o
 				break
 			}
 		}
 		if field != "" {
-			v := t.Value[len(field)+1:]
+			v := t.Value[len(op)+1:]
 			if strings.HasPrefix(v, "-") {
 				negated = true
 				v = v[1:]
 			}
+			switch {
+			case field == "linkedfrom":
+				return linkedFromQuery(v), negated
+			case field == "links" && !strings.Contains(v, "*"):
+				return LinksQuery(v), negated
+			}
+			if slices.Contains(dateFields, field) {
+				if q := dateQuery(field, v); q != nil {
+					return q, negated
+				}
+				return query.NewMatchNoneQuery(), negated
+			}
+			if field == "content_type" {
+				v = contentType(v)
+			}
 			if strings.Contains(v, "*") {
 				q := bleve.NewWildcardQuery(strings.ToLower(v))
 				q.SetField(field)
 				q.SetBoost(weights[field])
 				return q, negated
 			}
-			if field == "url" || field == "domain" {
+			if slices.Contains(keywordFields, field) || strings.HasPrefix(field, "meta.") {
 				q := bleve.NewTermQuery(strings.ToLower(v))
 				q.SetField(field)
 				q.SetBoost(weights[field])
 				return q, negated
 			}
+			if len(v) > 1 && strings.HasPrefix(v, `"`) && strings.HasSuffix(v, `"`) {
+				q := bleve.NewMatchPhraseQuery(v[1 : len(v)-1])
+				q.SetField(field)
+				q.SetBoost(weights[field])
+				return q, negated
+			}
 			q := bleve.NewMatchQuery(v)
 			q.SetField(field)
 			q.SetBoost(weights[field])
@@ -88,6 +211,7 @@ func getTokenQuery(t Token) (query.Query, bool) {
 			t.Value = t.Value[1:]
 		}
 
+		langs := IndexedLanguages()
 		qs := []query.Query{}
 		for _, f := range []string{"title", "text"} {
 			if strings.Contains(t.Value, "*") {
@@ -100,8 +224,29 @@ func getTokenQuery(t Token) (query.Query, bool) {
 				q.SetField(f)
 				q.SetBoost(weights[f])
 				qs = append(qs, q)
+				for _, l := range langs {
+					lq := bleve.NewMatchQuery(t.Value)
+					lq.SetField(f + "_" + l)
+					lq.SetBoost(weights[f] * languageWeight)
+					qs = append(qs, lq)
+				}
 			}
 		}
+		if !strings.Contains(t.Value, "*") {
+			noteq := bleve.NewMatchQuery(t.Value)
+			noteq.SetField("note")
+			noteq.SetBoost(weights["note"])
+			descq := bleve.NewMatchQuery(t.Value)
+			descq.SetField("description")
+			descq.SetBoost(weights["description"])
+			headq := bleve.NewMatchQuery(t.Value)
+			headq.SetField("headings.text")
+			headq.SetBoost(weights["headings.text"])
+			tagq := bleve.NewTermQuery(strings.ToLower(t.Value))
+			tagq.SetField("tags")
+			tagq.SetBoost(weights["tags"])
+			qs = append(qs, noteq, descq, headq, tagq)
+		}
 		wcq := t.Value
 		if !strings.Contains(t.Value, "*") {
 			if negated {
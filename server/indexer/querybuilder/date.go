@@ -0,0 +1,93 @@
+package querybuilder
+
+import (
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// dateFields are numeric timestamp fields filtered by date ranges
+var dateFields = []string{"published"}
+
+var dateLayouts = []string{"2006-01-02", "2006-01", "2006"}
+
+// parsePeriod returns the start and the end of the day, month or year of a date
+func parsePeriod(s string) (time.Time, time.Time, bool) {
+	for _, l := range dateLayouts {
+		t, err := time.ParseInLocation(l, s, time.Local)
+		if err != nil {
+			continue
+		}
+		switch l {
+		case "2006":
+			return t, t.AddDate(1, 0, 0), true
+		case "2006-01":
+			return t, t.AddDate(0, 1, 0), true
+		default:
+			return t, t.AddDate(0, 0, 1), true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// dateQuery creates a range query from a date filter value.
+// Supported forms are `2024`, `2024-05`, `2024-05-17`, `>2024`, `<2024-05` and `2023..2024-06`.
+// It returns nil if the value is not a valid date filter.
+func dateQuery(field, v string) query.Query {
+	var from, to time.Time
+	switch {
+	case strings.HasPrefix(v, ">"):
+		_, end, ok := parsePeriod(v[1:])
+		if !ok {
+			return nil
+		}
+		from = end
+	case strings.HasPrefix(v, "<"):
+		start, _, ok := parsePeriod(v[1:])
+		if !ok {
+			return nil
+		}
+		to = start
+	case strings.Contains(v, ".."):
+		fs, ts, _ := strings.Cut(v, "..")
+		if fs != "" {
+			start, _, ok := parsePeriod(fs)
+			if !ok {
+				return nil
+			}
+			from = start
+		}
+		if ts != "" {
+			_, end, ok := parsePeriod(ts)
+			if !ok {
+				return nil
+			}
+			to = end
+		}
+	default:
+		start, end, ok := parsePeriod(v)
+		if !ok {
+			return nil
+		}
+		from, to = start, end
+	}
+	var min, max *float64
+	if !from.IsZero() {
+		min = new(float64)
+		*min = float64(from.Unix())
+	}
+	if !to.IsZero() {
+		max = new(float64)
+		*max = float64(to.Unix())
+	}
+	if min == nil && max == nil {
+		return nil
+	}
+	inclusive := true
+	exclusive := false
+	q := bleve.NewNumericRangeInclusiveQuery(min, max, &inclusive, &exclusive)
+	q.SetField(field)
+	return q
+}
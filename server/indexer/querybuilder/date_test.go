@@ -0,0 +1,103 @@
+package querybuilder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		s          string
+		start, end time.Time
+		ok         bool
+	}{
+		{"2024", date(2024, 1, 1), date(2025, 1, 1), true},
+		{"2024-05", date(2024, 5, 1), date(2024, 6, 1), true},
+		{"2024-12", date(2024, 12, 1), date(2025, 1, 1), true},
+		{"2024-05-17", date(2024, 5, 17), date(2024, 5, 18), true},
+		{"2024-02-29", date(2024, 2, 29), date(2024, 3, 1), true},
+		{"2023-02-29", time.Time{}, time.Time{}, false},
+		{"2024-13", time.Time{}, time.Time{}, false},
+		{"24", time.Time{}, time.Time{}, false},
+		{"yesterday", time.Time{}, time.Time{}, false},
+		{"", time.Time{}, time.Time{}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.s, func(t *testing.T) {
+			start, end, ok := parsePeriod(tc.s)
+			if ok != tc.ok || !start.Equal(tc.start) || !end.Equal(tc.end) {
+				t.Errorf("parsePeriod(%q) = %v, %v, %v, want %v, %v, %v", tc.s, start, end, ok, tc.start, tc.end, tc.ok)
+			}
+		})
+	}
+}
+
+func TestDateQuery(t *testing.T) {
+	unix := func(t time.Time) *float64 {
+		f := float64(t.Unix())
+		return &f
+	}
+	tests := []struct {
+		v        string
+		min, max *float64
+		invalid  bool
+	}{
+		{v: "2024", min: unix(date(2024, 1, 1)), max: unix(date(2025, 1, 1))},
+		{v: "2024-05-17", min: unix(date(2024, 5, 17)), max: unix(date(2024, 5, 18))},
+		{v: ">2024", min: unix(date(2025, 1, 1))},
+		{v: ">2024-05", min: unix(date(2024, 6, 1))},
+		{v: "<2024-05", max: unix(date(2024, 5, 1))},
+		{v: "2023..2024-06", min: unix(date(2023, 1, 1)), max: unix(date(2024, 7, 1))},
+		{v: "2023..", min: unix(date(2023, 1, 1))},
+		{v: "..2024", max: unix(date(2025, 1, 1))},
+		{v: "..", invalid: true},
+		{v: ">", invalid: true},
+		{v: "<may", invalid: true},
+		{v: "2023..june", invalid: true},
+		{v: "last-week", invalid: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.v, func(t *testing.T) {
+			q := dateQuery("published", tc.v)
+			if tc.invalid {
+				if q != nil {
+					t.Errorf("dateQuery(%q) = %v, want nil", tc.v, q)
+				}
+				return
+			}
+			nq, ok := q.(*query.NumericRangeQuery)
+			if !ok {
+				t.Fatalf("dateQuery(%q) = %T, want *query.NumericRangeQuery", tc.v, q)
+			}
+			if nq.Field() != "published" {
+				t.Errorf("dateQuery(%q) field = %q, want published", tc.v, nq.Field())
+			}
+			if !equalBound(nq.Min, tc.min) || !equalBound(nq.Max, tc.max) {
+				t.Errorf("dateQuery(%q) range = %v..%v, want %v..%v", tc.v, bound(nq.Min), bound(nq.Max), bound(tc.min), bound(tc.max))
+			}
+			if nq.InclusiveMin == nil || !*nq.InclusiveMin || nq.InclusiveMax == nil || *nq.InclusiveMax {
+				t.Errorf("dateQuery(%q) range must include the start and exclude the end", tc.v)
+			}
+		})
+	}
+}
+
+func equalBound(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func bound(f *float64) any {
+	if f == nil {
+		return "unbounded"
+	}
+	return time.Unix(int64(*f), 0).Format(time.DateOnly)
+}
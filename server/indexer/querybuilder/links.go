@@ -0,0 +1,48 @@
+package querybuilder
+
+import (
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// URLForms returns the alternative forms of the URL values of link operators.
+// It is replaced by the indexer to apply the configured URL normalization.
+var URLForms = func(u string) []string {
+	return []string{u}
+}
+
+// OutgoingLinks returns the links of the indexed document of a URL.
+// It is replaced by the indexer to resolve the linkedfrom operator.
+var OutgoingLinks = func(_ string) []string {
+	return nil
+}
+
+// LinksQuery matches documents linking to the URL
+func LinksQuery(u string) query.Query {
+	qs := []query.Query{}
+	for _, v := range URLForms(u) {
+		q := bleve.NewTermQuery(strings.ToLower(v))
+		q.SetField("links")
+		qs = append(qs, q)
+	}
+	return bleve.NewDisjunctionQuery(qs...)
+}
+
+// linkedFromQuery matches the documents linked from the document of the URL
+func linkedFromQuery(u string) query.Query {
+	links := OutgoingLinks(u)
+	if len(links) == 0 {
+		return query.NewMatchNoneQuery()
+	}
+	qs := make([]query.Query, 0, len(links))
+	for _, l := range links {
+		for _, v := range URLForms(l) {
+			q := bleve.NewTermQuery(strings.ToLower(v))
+			q.SetField("url")
+			qs = append(qs, q)
+		}
+	}
+	return bleve.NewDisjunctionQuery(qs...)
+}
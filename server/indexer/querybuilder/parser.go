@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type TokenType int
@@ -36,17 +37,20 @@ func New(input string) *Lexer {
 func (l *Lexer) readChar() {
 	if l.pos >= len(l.input) {
 		l.char = 0
-	} else {
-		l.char = rune(l.input[l.pos])
+		l.pos++
+		return
 	}
-	l.pos++
+	r, w := utf8.DecodeRuneInString(l.input[l.pos:])
+	l.char = r
+	l.pos += w
 }
 
 func (l *Lexer) peekChar() rune {
 	if l.pos >= len(l.input) {
 		return 0
 	}
-	return rune(l.input[l.pos])
+	r, _ := utf8.DecodeRuneInString(l.input[l.pos:])
+	return r
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -205,3 +209,45 @@ func Tokenize(input string) ([]Token, error) {
 
 	return tokens, nil
 }
+
+// offset returns the byte offset of the current character
+func (l *Lexer) offset() int {
+	if l.char == 0 {
+		return min(l.pos-1, len(l.input))
+	}
+	return l.pos - utf8.RuneLen(l.char)
+}
+
+// ReplaceWords returns the query with its plain search words replaced by fn.
+// Operators, phrases, alternations and wildcard words are kept unchanged.
+func ReplaceWords(input string, fn func(string) string) string {
+	lexer := New(input)
+	var sb strings.Builder
+	last := 0
+	for {
+		lexer.skipWhitespace()
+		start := lexer.offset()
+		token, err := lexer.NextToken()
+		if err != nil {
+			return input
+		}
+		if token.Type == TokenEOF {
+			break
+		}
+		if token.Type != TokenWord {
+			continue
+		}
+		prefix, w := "", token.Value
+		if strings.HasPrefix(w, "-") {
+			prefix, w = "-", w[1:]
+		}
+		if w == "" || strings.ContainsAny(w, `:*"()|`) {
+			continue
+		}
+		sb.WriteString(input[last:start])
+		sb.WriteString(prefix + fn(w))
+		last = lexer.offset()
+	}
+	sb.WriteString(input[last:])
+	return sb.String()
+}
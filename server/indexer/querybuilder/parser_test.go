@@ -0,0 +1,31 @@
+package querybuilder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplaceWords(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"hello world", "HELLO WORLD"},
+		{"  spaced   words  ", "  SPACED   WORDS  "},
+		{"héllo wörld", "HÉLLO WÖRLD"},
+		{"-excluded word", "-EXCLUDED WORD"},
+		{`"exact phrase" word`, `"exact phrase" WORD`},
+		{"domain:example.com word", "domain:example.com WORD"},
+		{"wild* card", "wild* CARD"},
+		{"(a|b) c", "(a|b) C"},
+		{`unterminated "quote`, `unterminated "quote`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			if got := ReplaceWords(tc.input, strings.ToUpper); got != tc.want {
+				t.Errorf("ReplaceWords(%q) = %q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
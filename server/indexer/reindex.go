@@ -0,0 +1,451 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/model"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/rs/zerolog/log"
+)
+
+// ReindexOptions configures a reindex run.
+type ReindexOptions struct {
+	SkipSensitiveChecks bool
+	// Workers is the number of documents processed in parallel, defaults to the number of CPUs
+	Workers int
+}
+
+// ReindexStatus describes the progress of an online reindex job.
+type ReindexStatus struct {
+	Running    bool    `json:"running"`
+	Total      uint64  `json:"total"`
+	Processed  int     `json:"processed"`
+	Skipped    int     `json:"skipped"`
+	DocsPerSec float64 `json:"docs_per_sec"`
+	ETA        int64   `json:"eta"`
+	Started    int64   `json:"started"`
+	Finished   int64   `json:"finished"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type reindexProgress struct {
+	start     time.Time
+	total     uint64
+	processed int
+	skipped   int
+}
+
+const (
+	reindexBatchSize   = 100
+	reindexLogInterval = 5 * time.Second
+)
+
+var ErrReindexRunning = errors.New("reindex is already running")
+
+var (
+	reindexMu     sync.Mutex
+	reindexStatus = &ReindexStatus{}
+)
+
+// Reindex recreates the index from the stored documents. The server should be stopped.
+func Reindex(cfg *config.Config, opts *ReindexOptions) error {
+	configure(cfg)
+	idxPath := cfg.IndexPath()
+	tmpIdxPath := cfg.FullPath("tmp_index.db")
+	idx, err := bleve.Open(idxPath)
+	if err != nil {
+		return err
+	}
+	mapping := createMapping()
+	tmpIdx, err := bleve.New(tmpIdxPath, mapping)
+	if err != nil {
+		idx.Close()
+		return err
+	}
+	total, _ := idx.DocCount()
+	p := newReindexProgress(total)
+	lastLog := time.Now()
+	err = rebuild(idx.Search, cfg.Rules, opts, func(docs []*Document, skipped int) error {
+		b := tmpIdx.NewBatch()
+		for _, d := range docs {
+			// priority/score are updated implicitly by bleve
+			if err := b.Index(d.URL, d); err != nil {
+				return err
+			}
+		}
+		if err := tmpIdx.Batch(b); err != nil {
+			return err
+		}
+		p.add(len(docs), skipped)
+		if time.Since(lastLog) >= reindexLogInterval {
+			lastLog = time.Now()
+			p.log()
+		}
+		return nil
+	})
+	idx.Close()
+	tmpIdx.Close()
+	if err != nil {
+		os.RemoveAll(tmpIdxPath)
+		return err
+	}
+	p.log()
+	if err := os.RemoveAll(idxPath); err != nil {
+		return nil
+	}
+	return os.Rename(tmpIdxPath, idxPath)
+}
+
+// rebuild streams every stored document through a pool of workers
+// reprocessing them and passes the kept documents in batches to flush
+func rebuild(search func(*bleve.SearchRequest) (*bleve.SearchResult, error), rules *config.Rules, opts *ReindexOptions, flush func(docs []*Document, skipped int) error) error {
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		doc  *Document
+		keep bool
+		err  error
+	}
+	docs := make(chan *Document, workers*2)
+	results := make(chan result, workers*2)
+	var streamErr error
+	go func() {
+		defer close(docs)
+		streamErr = streamDocuments(search, func(d *Document) error {
+			select {
+			case docs <- d:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range docs {
+				log.Debug().Str("URL", d.URL).Msg("Indexing")
+				keep, err := reprocess(d, rules, opts.SkipSensitiveChecks)
+				select {
+				case results <- result{doc: d, keep: keep, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	batch := make([]*Document, 0, reindexBatchSize)
+	skipped := 0
+	for r := range results {
+		if r.err != nil {
+			return r.err
+		}
+		if r.keep {
+			batch = append(batch, r.doc)
+		} else {
+			skipped += 1
+		}
+		if len(batch)+skipped >= reindexBatchSize {
+			if err := flush(batch, skipped); err != nil {
+				return err
+			}
+			batch = batch[:0]
+			skipped = 0
+		}
+	}
+	if len(batch)+skipped > 0 {
+		if err := flush(batch, skipped); err != nil {
+			return err
+		}
+	}
+	if streamErr != nil && !errors.Is(streamErr, context.Canceled) {
+		return streamErr
+	}
+	return nil
+}
+
+// reprocess extracts the content of a stored document again.
+// It reports whether the document should be kept in the index.
+func reprocess(d *Document, rules *config.Rules, skipSensitiveChecks bool) (bool, error) {
+	added := d.Added
+	d.skipSensitiveCheck = skipSensitiveChecks
+	if err := d.Process(); err != nil {
+		if errors.Is(err, ErrSensitiveContent) {
+			log.Warn().Err(err).Str("URL", d.URL).Msg("Skipping document, sensitive content")
+			return false, nil
+		} else if errors.Is(err, ErrNoExtractor) {
+			log.Warn().Err(err).Str("URL", d.URL).Msg("Skipping document, can't extract content")
+			return false, nil
+		}
+		return false, err
+	}
+	if added != 0 {
+		d.Added = added
+	}
+	if rules.IsSkip(d.URL) {
+		log.Info().Str("URL", d.URL).Msg("Dropping URL that has since been added to skip rules.")
+		return false, nil
+	}
+	return true, nil
+}
+
+// ReindexProgress returns the status of the last online reindex job.
+func ReindexProgress() ReindexStatus {
+	reindexMu.Lock()
+	defer reindexMu.Unlock()
+	return *reindexStatus
+}
+
+func updateReindexStatus(fn func(*ReindexStatus)) {
+	reindexMu.Lock()
+	defer reindexMu.Unlock()
+	fn(reindexStatus)
+}
+
+// StartReindex rebuilds the index in the background while the current
+// index keeps serving requests. Documents added or deleted in the meantime
+// are written to both indexes. The new index replaces the current one
+// when the rebuild finishes.
+func StartReindex(cfg *config.Config, opts *ReindexOptions) error {
+	reindexMu.Lock()
+	if reindexStatus.Running {
+		reindexMu.Unlock()
+		return ErrReindexRunning
+	}
+	reindexStatus = &ReindexStatus{
+		Running: true,
+		Started: time.Now().Unix(),
+	}
+	reindexMu.Unlock()
+
+	tmpIdxPath := cfg.FullPath("tmp_index.db")
+	os.RemoveAll(tmpIdxPath)
+	tmpIdx, err := bleve.New(tmpIdxPath, createMapping())
+	if err != nil {
+		finishReindex(err)
+		return err
+	}
+	i.mu.Lock()
+	i.tmp = tmpIdx
+	i.dirty = make(map[string]bool)
+	i.mu.Unlock()
+	total, _ := i.docCount()
+	log.Info().Uint64("Documents", total).Msg("Online reindex started")
+
+	go func() {
+		err := runReindex(cfg, tmpIdx, opts)
+		if err == nil {
+			err = swapIndex(cfg.IndexPath(), tmpIdxPath)
+		} else {
+			i.mu.Lock()
+			i.tmp = nil
+			i.dirty = nil
+			i.mu.Unlock()
+			tmpIdx.Close()
+			os.RemoveAll(tmpIdxPath)
+		}
+		if err == nil && model.DB != nil {
+			err = model.SetIndexerVersion(Version)
+		}
+		finishReindex(err)
+	}()
+	return nil
+}
+
+func runReindex(cfg *config.Config, tmpIdx bleve.Index, opts *ReindexOptions) error {
+	total, _ := i.docCount()
+	p := newReindexProgress(total)
+	return rebuild(i.search, cfg.Rules, opts, func(docs []*Document, skipped int) error {
+		i.tmpMu.Lock()
+		b := tmpIdx.NewBatch()
+		for _, d := range docs {
+			// documents updated since the start of the job are already up to date
+			if i.dirty[d.URL] {
+				continue
+			}
+			if err := b.Index(d.URL, d); err != nil {
+				i.tmpMu.Unlock()
+				return err
+			}
+		}
+		err := tmpIdx.Batch(b)
+		i.tmpMu.Unlock()
+		if err != nil {
+			return err
+		}
+		p.add(len(docs), skipped)
+		if total, err := i.docCount(); err == nil {
+			p.total = max(total, uint64(p.processed))
+		}
+		updateReindexStatus(func(s *ReindexStatus) {
+			s.Total = p.total
+			s.Processed = p.processed
+			s.Skipped = p.skipped
+			s.DocsPerSec = p.rate()
+			s.ETA = int64(p.eta().Seconds())
+		})
+		return nil
+	})
+}
+
+// streamDocuments calls fn with every stored document in URL order
+func streamDocuments(search func(*bleve.SearchRequest) (*bleve.SearchResult, error), fn func(*Document) error) error {
+	var after []string
+	for {
+		req := bleve.NewSearchRequest(query.NewMatchAllQuery())
+		req.Size = 100
+		req.Fields = storedFields
+		req.SortBy([]string{"_id"})
+		req.SearchAfter = after
+		res, err := search(req)
+		if err != nil {
+			return err
+		}
+		if len(res.Hits) < 1 {
+			return nil
+		}
+		for _, h := range res.Hits {
+			if err := fn(docFromHit(h)); err != nil {
+				return err
+			}
+		}
+		after = []string{res.Hits[len(res.Hits)-1].ID}
+	}
+}
+
+// swapIndex replaces the live index with the rebuilt one.
+// The live index is moved aside until the rebuilt one is opened
+// and it is restored if any step of the swap fails.
+func swapIndex(idxPath, tmpIdxPath string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	tmp := i.tmp
+	i.tmp = nil
+	i.dirty = nil
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	oldIdxPath := idxPath + ".old"
+	if err := os.RemoveAll(oldIdxPath); err != nil {
+		return err
+	}
+	if err := i.idx.Close(); err != nil {
+		return reopenIndex(idxPath, err)
+	}
+	if err := os.Rename(idxPath, oldIdxPath); err != nil {
+		return reopenIndex(idxPath, err)
+	}
+	if err := os.Rename(tmpIdxPath, idxPath); err != nil {
+		return restoreIndex(idxPath, oldIdxPath, tmpIdxPath, err)
+	}
+	idx, err := bleve.Open(idxPath)
+	if err != nil {
+		return restoreIndex(idxPath, oldIdxPath, tmpIdxPath, err)
+	}
+	i.idx = idx
+	if err := os.RemoveAll(oldIdxPath); err != nil {
+		log.Warn().Err(err).Str("Path", oldIdxPath).Msg("Failed to remove the replaced index")
+	}
+	return i.loadLanguages()
+}
+
+// restoreIndex moves the rebuilt index back to its temporary path
+// and the previous index back to the live path after a failed swap
+func restoreIndex(idxPath, oldIdxPath, tmpIdxPath string, err error) error {
+	if _, serr := os.Stat(idxPath); serr == nil {
+		if rerr := os.Rename(idxPath, tmpIdxPath); rerr != nil {
+			return errors.Join(err, rerr)
+		}
+	}
+	if rerr := os.Rename(oldIdxPath, idxPath); rerr != nil {
+		return errors.Join(err, rerr)
+	}
+	return reopenIndex(idxPath, err)
+}
+
+// reopenIndex opens the live index again after a failed swap
+func reopenIndex(idxPath string, err error) error {
+	idx, oerr := bleve.Open(idxPath)
+	if oerr != nil {
+		return errors.Join(err, oerr)
+	}
+	i.idx = idx
+	log.Warn().Err(err).Msg("Failed to replace the index, the previous index is restored")
+	return err
+}
+
+func finishReindex(err error) {
+	updateReindexStatus(func(s *ReindexStatus) {
+		s.Running = false
+		s.Finished = time.Now().Unix()
+		if err != nil {
+			s.Error = err.Error()
+		}
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Online reindex failed")
+		return
+	}
+	log.Info().Msg("Online reindex finished")
+}
+
+func newReindexProgress(total uint64) *reindexProgress {
+	return &reindexProgress{
+		start: time.Now(),
+		total: total,
+	}
+}
+
+func (p *reindexProgress) add(kept, skipped int) {
+	p.processed += kept + skipped
+	p.skipped += skipped
+}
+
+// rate returns the number of processed documents per second
+func (p *reindexProgress) rate() float64 {
+	d := time.Since(p.start).Seconds()
+	if d == 0 {
+		return 0
+	}
+	return float64(p.processed) / d
+}
+
+// eta returns the estimated remaining time
+func (p *reindexProgress) eta() time.Duration {
+	r := p.rate()
+	if r == 0 || uint64(p.processed) >= p.total {
+		return 0
+	}
+	return time.Duration(float64(p.total-uint64(p.processed)) / r * float64(time.Second))
+}
+
+func (p *reindexProgress) log() {
+	log.Info().
+		Int("Processed", p.processed).
+		Uint64("Total", p.total).
+		Int("Skipped", p.skipped).
+		Str("Rate", fmt.Sprintf("%.1f docs/s", p.rate())).
+		Str("ETA", p.eta().Round(time.Second).String()).
+		Msg("Reindexing")
+}
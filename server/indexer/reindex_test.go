@@ -0,0 +1,215 @@
+package indexer
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/asciimoo/hister/config"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// waitForReindex waits until the online reindex job finishes and returns its status
+func waitForReindex(t *testing.T) ReindexStatus {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if s := ReindexProgress(); !s.Running {
+			return s
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("online reindex didn't finish")
+	return ReindexStatus{}
+}
+
+// addPagedTestDocuments indexes more documents than a reindex batch and returns their URLs
+func addPagedTestDocuments(t *testing.T) []string {
+	t.Helper()
+	urls := make([]string, reindexBatchSize*2+50)
+	for j := range urls {
+		urls[j] = fmt.Sprintf("https://example.com/%03d", j)
+		addTestDocument(t, urls[j], fmt.Sprintf("Page %d", j), "paged document")
+	}
+	return urls
+}
+
+// reindexTestIndex rebuilds the test index with Reindex, which opens the index itself
+func reindexTestIndex(t *testing.T, cfg *config.Config, opts *ReindexOptions) {
+	t.Helper()
+	if err := i.idx.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reindex(cfg, opts); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := bleve.Open(cfg.IndexPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.idx = idx
+}
+
+func TestStreamDocuments(t *testing.T) {
+	initTestIndex(t)
+	urls := addPagedTestDocuments(t)
+	searches := 0
+	search := func(req *bleve.SearchRequest) (*bleve.SearchResult, error) {
+		searches++
+		return i.search(req)
+	}
+	var streamed []string
+	err := streamDocuments(search, func(d *Document) error {
+		streamed = append(streamed, d.URL)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(streamed, urls) {
+		t.Errorf("streamed %d documents, want every document once in URL order", len(streamed))
+	}
+	if searches <= len(urls)/reindexBatchSize {
+		t.Errorf("searches = %d, want paging over %d documents", searches, len(urls))
+	}
+}
+
+func TestReindex(t *testing.T) {
+	cfg := initTestIndex(t)
+	urls := addPagedTestDocuments(t)
+	reindexTestIndex(t, cfg, &ReindexOptions{Workers: 4})
+	if n, err := i.idx.DocCount(); err != nil || n != uint64(len(urls)) {
+		t.Errorf("DocCount() = %d, %v, want %d", n, err, len(urls))
+	}
+	for _, u := range urls {
+		if GetByURL(u) == nil {
+			t.Errorf("%s is missing after reindex", u)
+		}
+	}
+}
+
+func TestStartReindex(t *testing.T) {
+	cfg := initTestIndex(t)
+	addTestDocument(t, "https://example.com/a", "Alpha", "reindexed document")
+	addTestDocument(t, "https://example.com/b", "Beta", "reindexed document")
+	if err := StartReindex(cfg, &ReindexOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	s := waitForReindex(t)
+	if s.Error != "" {
+		t.Fatalf("reindex error = %q", s.Error)
+	}
+	if s.Processed != 2 {
+		t.Errorf("processed = %d, want 2", s.Processed)
+	}
+	r, err := Search(cfg, &Query{Text: "reindexed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Documents) != 2 {
+		t.Errorf("found %d documents after reindex, want 2", len(r.Documents))
+	}
+	if _, err := os.Stat(cfg.FullPath("tmp_index.db")); !os.IsNotExist(err) {
+		t.Errorf("temporary index still exists: %v", err)
+	}
+}
+
+func TestReindexKeepsDocumentsWrittenDuringRebuild(t *testing.T) {
+	cfg := initTestIndex(t)
+	addTestDocument(t, "https://example.com/kept", "Kept", "before the rebuild")
+	addTestDocument(t, "https://example.com/deleted", "Deleted", "before the rebuild")
+
+	// start the job the way StartReindex does, the live updates after the
+	// rebuild only reach the rebuilt index through the double writes
+	tmpIdxPath := cfg.FullPath("tmp_index.db")
+	tmpIdx, err := bleve.New(tmpIdxPath, createMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.mu.Lock()
+	i.tmp = tmpIdx
+	i.dirty = make(map[string]bool)
+	i.mu.Unlock()
+
+	if err := runReindex(cfg, tmpIdx, &ReindexOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	addTestDocument(t, "https://example.com/added", "Added", "during the rebuild")
+	addTestDocument(t, "https://example.com/kept", "Kept", "updated during the rebuild")
+	if err := Delete("https://example.com/deleted"); err != nil {
+		t.Fatal(err)
+	}
+	if err := swapIndex(cfg.IndexPath(), tmpIdxPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if i.tmp != nil {
+		t.Error("temporary index is still set after the swap")
+	}
+	if d := GetByURL("https://example.com/added"); d == nil {
+		t.Error("document added during the rebuild is missing")
+	}
+	if d := GetByURL("https://example.com/kept"); d == nil || d.Text != "updated during the rebuild" {
+		t.Errorf("document updated during the rebuild = %+v", d)
+	}
+	if d := GetByURL("https://example.com/deleted"); d != nil {
+		t.Error("document deleted during the rebuild is in the swapped index")
+	}
+}
+
+func TestFailedReindexKeepsIndex(t *testing.T) {
+	cfg := initTestIndex(t)
+	addTestDocument(t, "https://example.com/a", "Alpha", "survivor")
+	// a stored document which can't be processed again fails the rebuild
+	if err := i.idx.Index("invalid", &Document{URL: "invalid", Title: "Invalid"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := StartReindex(cfg, &ReindexOptions{Workers: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if s := waitForReindex(t); s.Error == "" {
+		t.Fatal("reindex error is empty, want the rebuild error")
+	}
+	if i.tmp != nil {
+		t.Error("temporary index is still set after the failed rebuild")
+	}
+	if _, err := os.Stat(cfg.FullPath("tmp_index.db")); !os.IsNotExist(err) {
+		t.Errorf("temporary index still exists: %v", err)
+	}
+	r, err := Search(cfg, &Query{Text: "survivor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Documents) != 1 {
+		t.Errorf("found %d documents after the failed rebuild, want 1", len(r.Documents))
+	}
+}
+
+func TestFailedSwapRestoresIndex(t *testing.T) {
+	cfg := initTestIndex(t)
+	addTestDocument(t, "https://example.com/a", "Alpha", "survivor")
+	tmpIdxPath := cfg.FullPath("tmp_index.db")
+	tmpIdx, err := bleve.New(tmpIdxPath, createMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.tmp = tmpIdx
+	i.dirty = make(map[string]bool)
+	// the rebuilt index can't be moved to the live path
+	if err := swapIndex(cfg.IndexPath(), cfg.FullPath("missing_index.db")); err == nil {
+		t.Fatal("swapIndex() error = nil, want error")
+	}
+	if _, err := os.Stat(cfg.IndexPath() + ".old"); !os.IsNotExist(err) {
+		t.Errorf("moved aside index still exists: %v", err)
+	}
+	r, err := Search(cfg, &Query{Text: "survivor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Documents) != 1 {
+		t.Errorf("found %d documents after the failed swap, want 1", len(r.Documents))
+	}
+}
@@ -0,0 +1,92 @@
+package indexer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/asciimoo/hister/server/model"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type RevisionDiff struct {
+	From *model.Revision `json:"from"`
+	To   *model.Revision `json:"to"`
+	Diff string          `json:"diff"`
+}
+
+var ErrNoRevision = errors.New("revision not found")
+
+func (d *Document) contentHash() string {
+	h := sha256.New()
+	h.Write([]byte(d.Title))
+	h.Write([]byte{0})
+	h.Write([]byte(d.Text))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func storeRevision(d *Document) {
+	if model.DB == nil {
+		return
+	}
+	created, err := model.AddRevision(d.URL, d.contentHash(), d.Title, d.Text, d.HTML)
+	if err != nil {
+		log.Warn().Err(err).Str("URL", d.URL).Msg("Failed to store revision")
+		return
+	}
+	if created {
+		log.Debug().Str("URL", d.URL).Msg("New revision stored")
+	}
+}
+
+func Revisions(u string) ([]*model.Revision, error) {
+	return model.GetRevisions(u)
+}
+
+// DiffRevisions returns a unified diff of the text of two revisions of a URL.
+// If `to` is zero, the latest revision is used.
+func DiffRevisions(u string, from, to uint) (*RevisionDiff, error) {
+	fr, err := model.GetRevision(u, from)
+	if err != nil {
+		return nil, revisionError(err, from)
+	}
+	var tr *model.Revision
+	if to == 0 {
+		tr, err = model.GetLatestRevision(u)
+	} else {
+		tr, err = model.GetRevision(u, to)
+	}
+	if err != nil {
+		return nil, revisionError(err, to)
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(fr.Title + "\n\n" + fr.Text),
+		B:        difflib.SplitLines(tr.Title + "\n\n" + tr.Text),
+		FromFile: fmt.Sprintf("revision %d", fr.ID),
+		ToFile:   fmt.Sprintf("revision %d", tr.ID),
+		FromDate: fr.CreatedAt.Format("2006-01-02 15:04:05"),
+		ToDate:   tr.CreatedAt.Format("2006-01-02 15:04:05"),
+		Context:  3,
+	})
+	if err != nil {
+		return nil, err
+	}
+	fr.HTML, tr.HTML = "", ""
+	return &RevisionDiff{
+		From: fr,
+		To:   tr,
+		Diff: diff,
+	}, nil
+}
+
+// revisionError wraps missing revision errors into ErrNoRevision
+func revisionError(err error, id uint) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %d", ErrNoRevision, id)
+	}
+	return err
+}
@@ -0,0 +1,184 @@
+package indexer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/asciimoo/hister/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SensitiveMatch describes a sensitive content pattern found in a document
+type SensitiveMatch struct {
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+	Count   int    `json:"count"`
+}
+
+type sensitivePattern struct {
+	name   string
+	re     *regexp.Regexp
+	action *config.SensitiveContentAction
+}
+
+var sensitivePatterns []*sensitivePattern
+
+func configureSensitivePatterns(cfg *config.Config) {
+	sensitivePatterns = make([]*sensitivePattern, 0, len(cfg.SensitiveContentPatterns))
+	for k, v := range cfg.SensitiveContentPatterns {
+		sensitivePatterns = append(sensitivePatterns, &sensitivePattern{
+			name:   k,
+			re:     regexp.MustCompile(v),
+			action: cfg.SensitiveContentAction(k),
+		})
+	}
+	sort.Slice(sensitivePatterns, func(a, b int) bool {
+		return sensitivePatterns[a].name < sensitivePatterns[b].name
+	})
+}
+
+// redactedPlaceholder returns the replacement of the redacted matches of a pattern
+func redactedPlaceholder(name string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// checkSensitive applies the configured sensitive content actions to the document.
+// Matches of `redact` patterns are replaced in place, matches of `reject`
+// patterns and `allow-on-domains` patterns outside of their domains
+// result in ErrSensitiveContent.
+func (d *Document) checkSensitive() error {
+	d.sensitiveMatches = nil
+	return d.applySensitivePatterns()
+}
+
+// applySensitivePatterns checks the text fields of the document against the
+// sensitive content patterns and adds the matches to the previously found ones.
+// It is applied to the submitted content and again to the extracted fields,
+// as extraction can reveal matches, e.g. by decoding HTML entities.
+func (d *Document) applySensitivePatterns() error {
+	if d.skipSensitiveCheck || len(sensitivePatterns) == 0 {
+		return nil
+	}
+	host := d.Domain
+	if host == "" {
+		if pu, err := url.Parse(d.URL); err == nil {
+			host = pu.Hostname()
+		}
+	}
+	fields := d.textFields()
+	var rejected []string
+	for _, p := range sensitivePatterns {
+		// the same content is stored in multiple fields, count the most matches of a single field
+		n := 0
+		for _, f := range fields {
+			n = max(n, len(p.re.FindAllStringIndex(f, -1)))
+		}
+		if n == 0 {
+			continue
+		}
+		action := p.action.Action
+		switch action {
+		case config.SensitiveRedact:
+			r := redactedPlaceholder(p.name)
+			d.replaceTextFields(func(s string) string {
+				return p.re.ReplaceAllLiteralString(s, r)
+			})
+			if d.IsPDF() && d.Text != "" {
+				// PDF files can't be redacted, only the redacted extracted content is stored
+				d.Data = ""
+			}
+		case config.SensitiveAllowOnDomains:
+			if p.action.AllowedOn(host) {
+				action = "allow"
+			} else {
+				action = config.SensitiveReject
+				rejected = append(rejected, p.name)
+			}
+		default:
+			rejected = append(rejected, p.name)
+		}
+		d.addSensitiveMatch(p.name, action, n)
+	}
+	if len(d.sensitiveMatches) > 0 {
+		log.Debug().Str("URL", d.URL).Interface("patterns", d.sensitiveMatches).Msg("Matching sensitive content")
+	}
+	if len(rejected) > 0 {
+		return fmt.Errorf("%w: %s", ErrSensitiveContent, strings.Join(rejected, ", "))
+	}
+	return nil
+}
+
+// addSensitiveMatch records the matches of a pattern. Redacted matches
+// are gone on the next check, so their counts are summed, other matches
+// are found again, so the highest count is kept.
+func (d *Document) addSensitiveMatch(pattern, action string, n int) {
+	for _, m := range d.sensitiveMatches {
+		if m.Pattern != pattern {
+			continue
+		}
+		if action == config.SensitiveRedact {
+			m.Count += n
+		} else {
+			m.Count = max(m.Count, n)
+		}
+		return
+	}
+	d.sensitiveMatches = append(d.sensitiveMatches, &SensitiveMatch{
+		Pattern: pattern,
+		Action:  action,
+		Count:   n,
+	})
+}
+
+// textFields returns the text fields of the document stored in the index
+// including the raw content of text documents
+func (d *Document) textFields() []string {
+	fs := []string{d.Title, d.Text, d.HTML, d.Description, d.Code, d.Note, d.Author, d.SiteName, d.Image}
+	for _, h := range d.Headings {
+		fs = append(fs, h.Text, h.Anchor)
+	}
+	fs = append(fs, d.Links...)
+	for _, k := range slices.Sorted(maps.Keys(d.Meta)) {
+		fs = append(fs, d.Meta[k])
+	}
+	if t, ok := d.textData(); ok {
+		fs = append(fs, t)
+	}
+	return fs
+}
+
+// replaceTextFields replaces every text field returned by textFields with the result of fn
+func (d *Document) replaceTextFields(fn func(string) string) {
+	for _, f := range []*string{&d.Title, &d.Text, &d.HTML, &d.Description, &d.Code, &d.Note, &d.Author, &d.SiteName, &d.Image} {
+		*f = fn(*f)
+	}
+	for _, h := range d.Headings {
+		h.Text = fn(h.Text)
+		if strings.HasPrefix(h.Anchor, textDirective) {
+			h.Anchor = textFragment(h.Text)
+		} else {
+			h.Anchor = fn(h.Anchor)
+		}
+	}
+	for j, l := range d.Links {
+		d.Links[j] = fn(l)
+	}
+	for k, v := range d.Meta {
+		d.Meta[k] = fn(v)
+	}
+	if t, ok := d.textData(); ok {
+		d.Data = base64.StdEncoding.EncodeToString([]byte(fn(t)))
+	}
+}
+
+// SensitiveMatches returns the sensitive content patterns found in the document during processing
+func (d *Document) SensitiveMatches() []*SensitiveMatch {
+	return d.sensitiveMatches
+}
@@ -0,0 +1,144 @@
+package indexer
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/asciimoo/hister/config"
+)
+
+// configureTestSensitivePatterns replaces the sensitive content patterns with
+// a rejected, a redacted and an allowed on domains pattern
+func configureTestSensitivePatterns(cfg *config.Config) {
+	cfg.SensitiveContentPatterns = map[string]string{
+		"secret": `SECRET-\d+`,
+		"token":  `tok_[a-z]+`,
+		"email":  `[a-z]+@corp\.com`,
+	}
+	cfg.SensitiveContentActions = map[string]*config.SensitiveContentAction{
+		"token": {Action: config.SensitiveRedact},
+		"email": {Action: config.SensitiveAllowOnDomains, Domains: []string{"corp.com"}},
+	}
+	configureSensitivePatterns(cfg)
+}
+
+func TestSensitiveContentActions(t *testing.T) {
+	cfg := initTestIndex(t)
+	configureTestSensitivePatterns(cfg)
+	tests := []struct {
+		name     string
+		url      string
+		text     string
+		rejected bool
+		redacted string
+		matches  []SensitiveMatch
+	}{
+		{
+			name: "no match",
+			url:  "https://example.com/",
+			text: "nothing to see",
+		},
+		{
+			name:     "reject",
+			url:      "https://example.com/",
+			text:     "key SECRET-1 and SECRET-2",
+			rejected: true,
+			matches:  []SensitiveMatch{{Pattern: "secret", Action: config.SensitiveReject, Count: 2}},
+		},
+		{
+			name:     "redact",
+			url:      "https://example.com/",
+			text:     "token tok_abc",
+			redacted: "token [REDACTED:token]",
+			matches:  []SensitiveMatch{{Pattern: "token", Action: config.SensitiveRedact, Count: 1}},
+		},
+		{
+			name:    "allowed domain",
+			url:     "https://wiki.corp.com/",
+			text:    "mail bob@corp.com",
+			matches: []SensitiveMatch{{Pattern: "email", Action: "allow", Count: 1}},
+		},
+		{
+			name:     "other domain",
+			url:      "https://example.com/",
+			text:     "mail bob@corp.com",
+			rejected: true,
+			matches:  []SensitiveMatch{{Pattern: "email", Action: config.SensitiveReject, Count: 1}},
+		},
+		{
+			name:     "redact and reject",
+			url:      "https://example.com/",
+			text:     "tok_abc SECRET-1",
+			rejected: true,
+			matches: []SensitiveMatch{
+				{Pattern: "secret", Action: config.SensitiveReject, Count: 1},
+				{Pattern: "token", Action: config.SensitiveRedact, Count: 1},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Document{
+				URL:  tc.url,
+				HTML: "<html><head><title>Page</title></head><body><p>" + tc.text + "</p></body></html>",
+			}
+			err := d.Process()
+			if tc.rejected != errors.Is(err, ErrSensitiveContent) {
+				t.Fatalf("Process() error = %v, rejected = %t", err, tc.rejected)
+			}
+			if !tc.rejected && err != nil {
+				t.Fatal(err)
+			}
+			if tc.redacted != "" && d.Text != tc.redacted {
+				t.Errorf("text = %q, want %q", d.Text, tc.redacted)
+			}
+			ms := d.SensitiveMatches()
+			if len(ms) != len(tc.matches) {
+				t.Fatalf("matches = %d, want %d", len(ms), len(tc.matches))
+			}
+			for j, m := range ms {
+				if *m != tc.matches[j] {
+					t.Errorf("match %d = %+v, want %+v", j, *m, tc.matches[j])
+				}
+			}
+		})
+	}
+}
+
+func TestRedactTextFields(t *testing.T) {
+	cfg := initTestIndex(t)
+	configureTestSensitivePatterns(cfg)
+	d := &Document{
+		URL:         "https://example.com/",
+		Title:       "tok_title",
+		Text:        "tok_text",
+		HTML:        "<p>tok_html</p>",
+		Description: "tok_description",
+		Code:        "tok_code",
+		Note:        "tok_note",
+		Author:      "tok_author",
+		SiteName:    "tok_site",
+		Image:       "https://example.com/tok_image.png",
+		Headings:    []*Heading{{Text: "tok_heading", Anchor: "tok_anchor"}, {Text: "tok_directive", Anchor: textFragment("tok_directive")}},
+		Links:       []string{"https://example.com/tok_link"},
+		Meta:        map[string]string{"keywords": "tok_meta"},
+		ContentType: "text/plain",
+		Data:        base64.StdEncoding.EncodeToString([]byte("tok_data")),
+	}
+	if err := d.applySensitivePatterns(); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range d.textFields() {
+		if strings.Contains(f, "tok_") {
+			t.Errorf("field %q isn't redacted", f)
+		}
+	}
+	if d.Headings[1].Anchor != textFragment("[REDACTED:token]") {
+		t.Errorf("text directive anchor = %q, want the directive of the redacted heading", d.Headings[1].Anchor)
+	}
+	if d.Data == "" {
+		t.Error("data of a text document is cleared instead of redacted")
+	}
+}
@@ -0,0 +1,487 @@
+package indexer
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// metaKeys are the structured fields of the documents extracted by site specific extractors
+var metaKeys = []string{"site", "repo", "issue", "kind", "state", "score", "answers", "accepted", "comments", "topics", "subreddit", "item", "link", "section"}
+
+var (
+	githubPathRe        = regexp.MustCompile(`^/([^/]+)/([^/]+)(?:/(issues|pull|discussions)/(\d+))?/?$`)
+	stackOverflowPathRe = regexp.MustCompile(`^/questions/(\d+)`)
+	redditPathRe        = regexp.MustCompile(`^/r/([^/]+)/comments/`)
+	leadingNumberRe     = regexp.MustCompile(`-?\d+`)
+	blockElements       = []atom.Atom{atom.P, atom.Div, atom.Pre, atom.Li, atom.Br, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Tr, atom.Blockquote, atom.Section, atom.Article, atom.Dd, atom.Dt}
+	githubReservedPaths = []string{"settings", "orgs", "notifications", "marketplace", "explore", "topics", "sponsors", "login", "features", "collections", "trending", "search"}
+	stackExchangeHosts  = []string{"stackoverflow.com", "stackexchange.com", "superuser.com", "serverfault.com", "askubuntu.com", "mathoverflow.net", "stackapps.com"}
+)
+
+var errNoSiteContent = errors.New("no content found")
+
+type githubExtractor struct{}
+
+type stackOverflowExtractor struct{}
+
+type redditExtractor struct{}
+
+type hackerNewsExtractor struct{}
+
+type mdnExtractor struct{}
+
+// metaMapping indexes the structured fields as lowercase keywords,
+// the comma separated topics are indexed as separate keywords
+func metaMapping() *mapping.DocumentMapping {
+	m := bleve.NewDocumentMapping()
+	for _, k := range metaKeys {
+		fm := bleve.NewTextFieldMapping()
+		fm.Analyzer = "url"
+		if k == "topics" {
+			fm.Analyzer = "list"
+		}
+		m.AddFieldMappingsAt(k, fm)
+	}
+	return m
+}
+
+func metaFields() []string {
+	fs := make([]string, len(metaKeys))
+	for j, k := range metaKeys {
+		fs[j] = "meta." + k
+	}
+	return fs
+}
+
+func readMeta(h *search.DocumentMatch) map[string]string {
+	var m map[string]string
+	for _, k := range metaKeys {
+		if s, ok := h.Fields["meta."+k].(string); ok {
+			if m == nil {
+				m = make(map[string]string)
+			}
+			m[k] = s
+		}
+	}
+	return m
+}
+
+// siteURL returns the parsed URL of the document if its host is one of the given domains or their subdomains
+func siteURL(d *Document, domains ...string) *url.URL {
+	if !d.isHTML() {
+		return nil
+	}
+	pu, err := url.Parse(d.URL)
+	if err != nil {
+		return nil
+	}
+	host := strings.ToLower(pu.Hostname())
+	for _, dom := range domains {
+		if host == dom || strings.HasSuffix(host, "."+dom) {
+			return pu
+		}
+	}
+	return nil
+}
+
+func parseDocument(d *Document) (*html.Node, error) {
+	return html.Parse(strings.NewReader(d.HTML))
+}
+
+func selectAll(n *html.Node, sel string) []*html.Node {
+	return cascadia.MustCompile(sel).MatchAll(n)
+}
+
+func selectFirst(n *html.Node, sel string) *html.Node {
+	return cascadia.MustCompile(sel).MatchFirst(n)
+}
+
+func attr(n *html.Node, name string) string {
+	if n == nil {
+		return ""
+	}
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// nodeText returns the text content of a node keeping the line breaks of block elements
+func nodeText(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			sb.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Svg, atom.Button, atom.Template:
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && slices.Contains(blockElements, n.DataAtom) {
+			sb.WriteString("\n")
+		}
+	}
+	walk(n)
+	lines := strings.Split(sb.String(), "\n")
+	ret := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l = strings.Join(strings.Fields(l), " "); l != "" {
+			ret = append(ret, l)
+		}
+	}
+	return strings.Join(ret, "\n")
+}
+
+func selectText(n *html.Node, sel string) string {
+	return nodeText(selectFirst(n, sel))
+}
+
+// firstNumber returns the first integer found in s
+func firstNumber(s string) string {
+	return leadingNumberRe.FindString(strings.ReplaceAll(s, ",", ""))
+}
+
+// setMeta adds the non-empty values to the structured fields of the document
+func (d *Document) setMeta(kv ...string) {
+	for j := 0; j+1 < len(kv); j += 2 {
+		if kv[j+1] == "" {
+			continue
+		}
+		if d.Meta == nil {
+			d.Meta = make(map[string]string)
+		}
+		d.Meta[kv[j]] = kv[j+1]
+	}
+}
+
+// textSections joins non-empty sections with a blank line
+func textSections(sections ...string) string {
+	return strings.Join(slices.DeleteFunc(sections, func(s string) bool {
+		return strings.TrimSpace(s) == ""
+	}), "\n\n")
+}
+
+func (e *githubExtractor) Name() string {
+	return "GitHub"
+}
+
+func (e *githubExtractor) Match(d *Document) bool {
+	pu := siteURL(d, "github.com")
+	if pu == nil || pu.Hostname() != "github.com" {
+		return false
+	}
+	m := githubPathRe.FindStringSubmatch(pu.Path)
+	return m != nil && !slices.Contains(githubReservedPaths, m[1])
+}
+
+func (e *githubExtractor) Extract(d *Document) error {
+	pu := siteURL(d, "github.com")
+	m := githubPathRe.FindStringSubmatch(pu.Path)
+	doc, err := parseDocument(d)
+	if err != nil {
+		return err
+	}
+	repo := m[1] + "/" + m[2]
+	var title string
+	var bodies []string
+	for _, n := range selectAll(doc, ".markdown-body") {
+		// skip containers of nested markdown bodies, the matches include the node itself
+		if len(selectAll(n, ".markdown-body")) > 1 {
+			continue
+		}
+		if t := nodeText(n); t != "" {
+			bodies = append(bodies, t)
+		}
+	}
+	if m[4] == "" {
+		title = selectText(doc, "title")
+		d.setMeta("site", "github", "repo", repo, "kind", "repository")
+	} else {
+		title = selectText(doc, `[data-testid="issue-title"], .js-issue-title, h1 .markdown-title, h1 bdi`)
+		state := selectText(doc, `[data-testid="header-state"], .gh-header-meta .State, span.State`)
+		kind := map[string]string{"issues": "issue", "pull": "pull", "discussions": "discussion"}[m[3]]
+		comments := ""
+		if len(bodies) > 0 {
+			comments = strconv.Itoa(len(bodies) - 1)
+		}
+		d.setMeta("site", "github", "repo", repo, "issue", m[4], "kind", kind, "state", strings.ToLower(state), "comments", comments)
+		d.Author = selectText(doc, `.timeline-comment-header .author, [data-testid="issue-body-header-author"]`)
+		if title != "" {
+			title += " · #" + m[4]
+		}
+	}
+	if title == "" {
+		title = selectText(doc, "title")
+	}
+	if len(bodies) == 0 {
+		return errNoSiteContent
+	}
+	d.Title = title
+	d.Text = textSections(bodies...)
+	return nil
+}
+
+func (e *stackOverflowExtractor) Name() string {
+	return "StackOverflow"
+}
+
+func (e *stackOverflowExtractor) Match(d *Document) bool {
+	pu := siteURL(d, stackExchangeHosts...)
+	return pu != nil && stackOverflowPathRe.MatchString(pu.Path)
+}
+
+func (e *stackOverflowExtractor) Extract(d *Document) error {
+	pu := siteURL(d, stackExchangeHosts...)
+	doc, err := parseDocument(d)
+	if err != nil {
+		return err
+	}
+	q := selectFirst(doc, "#question")
+	if q == nil {
+		return errNoSiteContent
+	}
+	question := selectText(q, ".js-post-body, .s-prose")
+	score := attr(selectFirst(q, ".js-vote-count"), "data-value")
+	if score == "" {
+		score = firstNumber(selectText(q, ".js-vote-count"))
+	}
+	var accepted string
+	var answers []string
+	for _, a := range selectAll(doc, "#answers .answer") {
+		body := selectText(a, ".js-post-body, .s-prose")
+		if body == "" {
+			continue
+		}
+		as := attr(selectFirst(a, ".js-vote-count"), "data-value")
+		if as == "" {
+			as = firstNumber(selectText(a, ".js-vote-count"))
+		}
+		if slices.Contains(strings.Fields(attr(a, "class")), "accepted-answer") {
+			accepted = "Accepted answer (score " + as + ")\n" + body
+			continue
+		}
+		answers = append(answers, "Answer (score "+as+")\n"+body)
+	}
+	var topics []string
+	for _, t := range selectAll(doc, ".post-taglist .post-tag") {
+		topics = append(topics, nodeText(t))
+	}
+	host := strings.TrimPrefix(pu.Hostname(), "www.")
+	d.setMeta(
+		"site", strings.TrimSuffix(host, ".com"),
+		"item", stackOverflowPathRe.FindStringSubmatch(pu.Path)[1],
+		"score", score,
+		"answers", strconv.Itoa(len(answers)+min(len(accepted), 1)),
+		"accepted", strconv.FormatBool(accepted != ""),
+		"topics", strings.Join(topics, ","),
+	)
+	d.Author = selectText(q, ".post-signature.owner .user-details a, .post-signature .user-details a")
+	d.Title = selectText(doc, "#question-header h1")
+	if d.Title == "" {
+		d.Title = selectText(doc, "title")
+	}
+	d.Text = textSections(append([]string{question, accepted}, answers...)...)
+	return nil
+}
+
+func (e *redditExtractor) Name() string {
+	return "Reddit"
+}
+
+func (e *redditExtractor) Match(d *Document) bool {
+	pu := siteURL(d, "reddit.com")
+	return pu != nil && redditPathRe.MatchString(pu.Path)
+}
+
+func (e *redditExtractor) Extract(d *Document) error {
+	pu := siteURL(d, "reddit.com")
+	doc, err := parseDocument(d)
+	if err != nil {
+		return err
+	}
+	subreddit := strings.ToLower(redditPathRe.FindStringSubmatch(pu.Path)[1])
+	var body, score, title string
+	var comments []string
+	if p := selectFirst(doc, "shreddit-post"); p != nil {
+		// current reddit layout
+		title = attr(p, "post-title")
+		score = attr(p, "score")
+		d.Author = attr(p, "author")
+		body = selectText(p, `[slot="text-body"]`)
+		for _, c := range selectAll(doc, "shreddit-comment") {
+			if t := selectText(c, `[slot="comment"]`); t != "" {
+				comments = append(comments, attr(c, "author")+": "+t)
+			}
+		}
+	} else if p := selectFirst(doc, "#siteTable .thing.link"); p != nil {
+		// old.reddit.com layout
+		title = selectText(p, "a.title")
+		score = attr(p, "data-score")
+		d.Author = attr(p, "data-author")
+		body = selectText(p, ".expando .usertext-body .md")
+		for _, c := range selectAll(doc, ".commentarea .thing.comment") {
+			if t := selectText(c, ".usertext-body .md"); t != "" {
+				comments = append(comments, attr(c, "data-author")+": "+t)
+			}
+		}
+	} else {
+		return errNoSiteContent
+	}
+	d.setMeta("site", "reddit", "subreddit", subreddit, "score", score, "comments", strconv.Itoa(len(comments)))
+	d.Title = title
+	if d.Title == "" {
+		d.Title = selectText(doc, "title")
+	}
+	d.Text = textSections(append([]string{body}, comments...)...)
+	if d.Text == "" && d.Title == "" {
+		return errNoSiteContent
+	}
+	return nil
+}
+
+func (e *hackerNewsExtractor) Name() string {
+	return "HackerNews"
+}
+
+func (e *hackerNewsExtractor) Match(d *Document) bool {
+	pu := siteURL(d, "news.ycombinator.com")
+	return pu != nil && pu.Path == "/item" && pu.Query().Get("id") != ""
+}
+
+func (e *hackerNewsExtractor) Extract(d *Document) error {
+	pu := siteURL(d, "news.ycombinator.com")
+	doc, err := parseDocument(d)
+	if err != nil {
+		return err
+	}
+	link := selectFirst(doc, ".titleline > a")
+	var comments []string
+	for _, c := range selectAll(doc, ".comtr") {
+		if t := selectText(c, ".commtext"); t != "" {
+			comments = append(comments, selectText(c, ".hnuser")+": "+t)
+		}
+	}
+	story := selectText(doc, ".toptext")
+	if link == nil && story == "" && len(comments) == 0 {
+		return errNoSiteContent
+	}
+	d.setMeta(
+		"site", "hackernews",
+		"item", pu.Query().Get("id"),
+		"score", firstNumber(selectText(doc, ".subline .score, .score")),
+		"comments", strconv.Itoa(len(comments)),
+		"link", attr(link, "href"),
+	)
+	d.Author = selectText(doc, ".subline .hnuser")
+	d.Title = nodeText(link)
+	if d.Title == "" {
+		d.Title = selectText(doc, "title")
+	}
+	d.Text = textSections(append([]string{story}, comments...)...)
+	return nil
+}
+
+func (e *mdnExtractor) Name() string {
+	return "MDN"
+}
+
+func (e *mdnExtractor) Match(d *Document) bool {
+	pu := siteURL(d, "developer.mozilla.org")
+	return pu != nil && strings.Contains(pu.Path, "/docs/")
+}
+
+func (e *mdnExtractor) Extract(d *Document) error {
+	doc, err := parseDocument(d)
+	if err != nil {
+		return err
+	}
+	content := selectFirst(doc, ".main-page-content, main article, main")
+	if content == nil {
+		return errNoSiteContent
+	}
+	var section []string
+	for _, b := range selectAll(doc, ".breadcrumbs-container li a, nav.breadcrumbs a") {
+		if t := nodeText(b); t != "" {
+			section = append(section, t)
+		}
+	}
+	d.setMeta("site", "mdn", "section", strings.Join(section, " / "))
+	d.Title = selectText(content, "h1")
+	if d.Title == "" {
+		d.Title = selectText(doc, "title")
+	}
+	d.Text = nodeText(content)
+	if d.Text == "" {
+		return errNoSiteContent
+	}
+	return nil
+}
+
+func plural(n, word string) string {
+	if n == "1" {
+		return n + " " + word
+	}
+	return n + " " + word + "s"
+}
+
+// MetaSummary returns a short human readable description of the structured fields
+func (d *Document) MetaSummary() string {
+	m := d.Meta
+	if len(m) == 0 {
+		return ""
+	}
+	var parts []string
+	if m["repo"] != "" {
+		if m["issue"] != "" {
+			parts = append(parts, m["repo"]+" #"+m["issue"])
+		} else {
+			parts = append(parts, m["repo"])
+		}
+	}
+	if m["subreddit"] != "" {
+		parts = append(parts, "r/"+m["subreddit"])
+	}
+	if m["section"] != "" {
+		parts = append(parts, m["section"])
+	}
+	if m["state"] != "" {
+		parts = append(parts, m["state"])
+	}
+	if m["score"] != "" {
+		parts = append(parts, plural(m["score"], "point"))
+	}
+	if m["answers"] != "" {
+		a := plural(m["answers"], "answer")
+		if m["accepted"] == "true" {
+			a += " (accepted)"
+		}
+		parts = append(parts, a)
+	}
+	if m["comments"] != "" {
+		parts = append(parts, plural(m["comments"], "comment"))
+	}
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,87 @@
+package indexer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// sortFields maps the accepted sort keys to index fields
+var sortFields = map[string]string{
+	"relevance": "_score",
+	"score":     "_score",
+	"added":     "added",
+	"date":      "added",
+	"title":     "title_sort",
+	"url":       "url",
+	"domain":    "domain",
+	"visits":    "visits",
+}
+
+// titleSortMapping indexes the whole lowercased title as a single term to sort by title
+func titleSortMapping() *mapping.FieldMapping {
+	fm := bleve.NewTextFieldMapping()
+	fm.Name = "title_sort"
+	fm.Analyzer = "url"
+	fm.Store = false
+	fm.IncludeInAll = false
+	fm.IncludeTermVectors = false
+	return fm
+}
+
+var sortAliases = map[string]string{
+	"newest": "added:desc",
+	"oldest": "added:asc",
+}
+
+// parseSort converts a comma separated list of sort keys to bleve sort order.
+// Keys are ascending by default (except relevance), direction can be set
+// explicitly with a `-` prefix or with `:asc`/`:desc` suffixes.
+// E.g.: "domain,newest" or "visits:desc,title"
+func parseSort(s string) ([]string, error) {
+	keys := strings.Split(s, ",")
+	order := make([]string, 0, len(keys))
+	for _, k := range keys {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if a, ok := sortAliases[k]; ok {
+			k = a
+		}
+		// relevance is descending unless the direction is explicitly set
+		desc := false
+		explicit := false
+		if strings.HasPrefix(k, "-") {
+			desc = true
+			explicit = true
+			k = k[1:]
+		}
+		if name, dir, ok := strings.Cut(k, ":"); ok {
+			switch dir {
+			case "asc":
+				desc = false
+			case "desc":
+				desc = true
+			default:
+				return nil, fmt.Errorf("%w: invalid sort direction %q", ErrInvalidQuery, dir)
+			}
+			explicit = true
+			k = name
+		}
+		if k == "frecency" {
+			return nil, fmt.Errorf("%w: frecency can't be combined with other sort keys", ErrInvalidQuery)
+		}
+		f, ok := sortFields[k]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown sort key %q", ErrInvalidQuery, k)
+		}
+		if f == "_score" && !explicit {
+			desc = true
+		}
+		if desc {
+			f = "-" + f
+		}
+		order = append(order, f)
+	}
+	return order, nil
+}
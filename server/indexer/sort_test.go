@@ -0,0 +1,81 @@
+package indexer
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		sort  string
+		order []string
+		err   bool
+	}{
+		{sort: "relevance", order: []string{"-_score"}},
+		{sort: "score:asc", order: []string{"_score"}},
+		{sort: "-score", order: []string{"-_score"}},
+		{sort: "title", order: []string{"title_sort"}},
+		{sort: "title:desc", order: []string{"-title_sort"}},
+		{sort: "-title", order: []string{"-title_sort"}},
+		{sort: "date", order: []string{"added"}},
+		{sort: "newest", order: []string{"-added"}},
+		{sort: "oldest", order: []string{"added"}},
+		{sort: "domain,newest", order: []string{"domain", "-added"}},
+		{sort: " Visits:DESC , title ", order: []string{"-visits", "title_sort"}},
+		{sort: "url,relevance", order: []string{"url", "-_score"}},
+		{sort: "unknown", err: true},
+		{sort: "title:up", err: true},
+		{sort: "frecency", err: true},
+		{sort: "visits,frecency", err: true},
+		{sort: "", err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.sort, func(t *testing.T) {
+			order, err := parseSort(tc.sort)
+			if tc.err {
+				if !errors.Is(err, ErrInvalidQuery) {
+					t.Errorf("parseSort(%q) error = %v, want ErrInvalidQuery", tc.sort, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSort(%q) error = %v", tc.sort, err)
+			}
+			if !slices.Equal(order, tc.order) {
+				t.Errorf("parseSort(%q) = %v, want %v", tc.sort, order, tc.order)
+			}
+		})
+	}
+}
+
+func TestTitleSortKeepsTitle(t *testing.T) {
+	cfg := initTestIndex(t)
+	// too short to detect its language, so only the default title mapping is used
+	addTestDocument(t, "https://example.com/b", "Beta", "xyz")
+	addTestDocument(t, "https://example.com/a", "Alpha", "qwe")
+	if _, err := UpdateTags("https://example.com/b", []string{"tag"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	d := GetByURL("https://example.com/b")
+	if d == nil {
+		t.Fatal("GetByURL() = nil")
+	}
+	if d.Title != "Beta" {
+		t.Fatalf("GetByURL() title = %q, want Beta", d.Title)
+	}
+	if d.Language != "" {
+		t.Fatalf("language = %q, want no detected language", d.Language)
+	}
+	r, err := Search(cfg, &Query{Text: "*", Sort: "title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, d := range r.Documents {
+		titles = append(titles, d.Title)
+	}
+	if !slices.Equal(titles, []string{"Alpha", "Beta"}) {
+		t.Errorf("titles sorted by title = %q, want [Alpha Beta]", titles)
+	}
+}
@@ -0,0 +1,166 @@
+package indexer
+
+import (
+	"errors"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/asciimoo/hister/server/indexer/querybuilder"
+
+	index "github.com/blevesearch/bleve_index_api"
+)
+
+// fewHits is the number of hits below which spelling corrections are suggested
+const fewHits = 3
+
+// minCorrectedLength is the minimum length of the corrected words
+const minCorrectedLength = 4
+
+// spellingFields are the fields providing the vocabulary of the spelling correction
+var spellingFields = []string{"title", "text"}
+
+// SpellingCorrection is the corrected form of a query having few or no hits
+type SpellingCorrection struct {
+	Query string `json:"query"`
+	// Applied reports whether the results belong to the corrected query
+	// because the original query had no hits
+	Applied bool `json:"applied"`
+}
+
+type termCandidate struct {
+	count    uint64
+	distance uint8
+}
+
+// correctSpelling suggests a better spelled version of the query for the results
+// or returns the results of the corrected query if the original one has no hits
+func correctSpelling(q *Query, r *Results) *Results {
+	if q.NoCorrection || r.Total >= fewHits || (r.Total > 0 && (q.Offset > 0 || q.After != "")) {
+		return r
+	}
+	ct := SpellingCorrect(q.Text)
+	if ct == q.Text {
+		return r
+	}
+	cq := *q
+	cq.Text = ct
+	cq.NoCorrection = true
+	if r.Total > 0 {
+		cq.Limit = 1
+		cq.Offset = 0
+		cq.After = ""
+		cq.Facets = false
+	}
+	cr, err := Search(q.cfg, &cq)
+	if err != nil || cr.Total <= r.Total {
+		return r
+	}
+	if r.Total > 0 {
+		r.Spelling = &SpellingCorrection{Query: ct}
+		return r
+	}
+	cr.Query = q
+	cr.Spelling = &SpellingCorrection{
+		Query:   ct,
+		Applied: true,
+	}
+	return cr
+}
+
+// SpellingCorrect replaces the misspelled search words of the query
+// with the most frequent similar terms of the indexed titles and texts
+func SpellingCorrect(q string) string {
+	return querybuilder.ReplaceWords(q, func(w string) string {
+		c, err := i.correctWord(w)
+		if err != nil || c == "" {
+			return w
+		}
+		return c
+	})
+}
+
+// correctWord returns the replacement of a misspelled word or an empty string.
+// Candidates are ranked by their document frequency divided by 10 to the power of their edit distance,
+// words found in the index are only replaced by far more frequent terms.
+func (ix *indexer) correctWord(w string) (string, error) {
+	lw := strings.ToLower(w)
+	n := utf8.RuneCountInString(lw)
+	if n < minCorrectedLength || strings.IndexFunc(lw, func(r rune) bool { return !unicode.IsLetter(r) }) != -1 {
+		return "", nil
+	}
+	fuzziness := 1
+	if n > 6 {
+		fuzziness = 2
+	}
+	cs, err := ix.similarTerms(lw, fuzziness)
+	if err != nil {
+		return "", err
+	}
+	best := ""
+	bestScore := 0.0
+	for _, t := range slices.Sorted(maps.Keys(cs)) {
+		c := cs[t]
+		if t == lw {
+			continue
+		}
+		if s := float64(c.count) / math.Pow(10, float64(c.distance)); s > bestScore {
+			best, bestScore = t, s
+		}
+	}
+	if best == "" || (cs[lw] != nil && cs[best].count < 100*cs[lw].count) {
+		return "", nil
+	}
+	if unicode.IsUpper([]rune(w)[0]) {
+		r := []rune(best)
+		r[0] = unicode.ToUpper(r[0])
+		best = string(r)
+	}
+	return best, nil
+}
+
+// similarTerms returns the terms of the spelling fields within the edit distance of the term
+func (ix *indexer) similarTerms(term string, fuzziness int) (map[string]*termCandidate, error) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	ai, err := ix.idx.Advanced()
+	if err != nil {
+		return nil, err
+	}
+	r, err := ai.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	fr, ok := r.(index.IndexReaderFuzzy)
+	if !ok {
+		return nil, errors.New("index does not support fuzzy term lookup")
+	}
+	ret := make(map[string]*termCandidate)
+	for _, f := range spellingFields {
+		dict, a, err := fr.FieldDictFuzzyAutomaton(f, term, fuzziness, "")
+		if err != nil {
+			return nil, err
+		}
+		for {
+			e, err := dict.Next()
+			if err != nil || e == nil {
+				break
+			}
+			_, dist := a.MatchAndDistance(e.Term)
+			if c, ok := ret[e.Term]; ok {
+				c.count += e.Count
+				continue
+			}
+			ret[e.Term] = &termCandidate{
+				count:    e.Count,
+				distance: dist,
+			}
+		}
+		dict.Close()
+	}
+	return ret, nil
+}
@@ -0,0 +1,189 @@
+package indexer
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/asciimoo/hister/server/model"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// Suggestion kinds
+const (
+	SuggestionQuery    = "query"
+	SuggestionDocument = "document"
+	SuggestionTerm     = "term"
+)
+
+const (
+	defaultSuggestions = 10
+	maxSuggestions     = 50
+	// minCompletedLength is the minimum length of the last word completed from the index
+	minCompletedLength = 2
+)
+
+// Suggestion is a completion of a partially typed query
+type Suggestion struct {
+	Text string `json:"text"`
+	Kind string `json:"kind"`
+	// URL of the suggested document
+	URL string `json:"url,omitempty"`
+	// Count is the number of searches of past queries, visits of documents
+	// or documents containing the index terms
+	Count uint64 `json:"count"`
+}
+
+// Suggest returns the completions of a query, past queries come first followed by
+// the titles of matching documents and the frequent index terms completing the last word.
+// Each group is ranked by use.
+func Suggest(q string, limit int) ([]*Suggestion, error) {
+	if limit <= 0 {
+		limit = defaultSuggestions
+	}
+	limit = min(limit, maxSuggestions)
+	var ret []*Suggestion
+	seen := make(map[string]bool)
+	add := func(ss []*Suggestion) {
+		for _, s := range ss {
+			k := strings.ToLower(s.Text)
+			if len(ret) == limit || seen[k] || k == strings.ToLower(q) {
+				continue
+			}
+			seen[k] = true
+			ret = append(ret, s)
+		}
+	}
+	if model.DB != nil {
+		qs, err := model.GetQuerySuggestions(q, limit)
+		if err != nil {
+			return nil, err
+		}
+		ss := make([]*Suggestion, 0, len(qs))
+		for _, hq := range qs {
+			ss = append(ss, &Suggestion{
+				Text:  hq.Query,
+				Kind:  SuggestionQuery,
+				Count: uint64(hq.Count),
+			})
+		}
+		add(ss)
+	}
+	words := strings.Fields(q)
+	if len(words) == 0 || slices.ContainsFunc(words, func(w string) bool {
+		return strings.ContainsAny(w, `:*"()|`) || strings.HasPrefix(w, "-")
+	}) {
+		return ret, nil
+	}
+	last := ""
+	if !strings.HasSuffix(q, " ") {
+		last = strings.ToLower(words[len(words)-1])
+		words = words[:len(words)-1]
+	}
+	if last != "" && len(last) < minCompletedLength {
+		return ret, nil
+	}
+	if len(ret) < limit {
+		ss, err := titleSuggestions(words, last, limit)
+		if err != nil {
+			return nil, err
+		}
+		add(ss)
+	}
+	if len(ret) < limit && last != "" {
+		ss, err := i.termSuggestions(words, last, limit)
+		if err != nil {
+			return nil, err
+		}
+		add(ss)
+	}
+	return ret, nil
+}
+
+// titleSuggestions returns the titles of the most visited documents containing
+// the words and a word starting with last in their titles
+func titleSuggestions(words []string, last string, limit int) ([]*Suggestion, error) {
+	var qs []query.Query
+	for _, w := range words {
+		mq := bleve.NewMatchQuery(w)
+		mq.SetField("title")
+		qs = append(qs, mq)
+	}
+	if last != "" {
+		pq := bleve.NewPrefixQuery(last)
+		pq.SetField("title")
+		qs = append(qs, pq)
+	}
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(qs...), limit, 0, false)
+	req.Fields = []string{"title", "visits"}
+	req.SortBy([]string{"-visits", "-_score"})
+	res, err := i.search(req)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*Suggestion, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		t, _ := h.Fields["title"].(string)
+		if t = strings.Join(strings.Fields(t), " "); t == "" {
+			continue
+		}
+		v, _ := h.Fields["visits"].(float64)
+		ret = append(ret, &Suggestion{
+			Text:  t,
+			Kind:  SuggestionDocument,
+			URL:   h.ID,
+			Count: uint64(v),
+		})
+	}
+	return ret, nil
+}
+
+// termSuggestions completes the last word of the query with the most frequent terms
+// of the titles and texts starting with it
+func (ix *indexer) termSuggestions(words []string, last string, limit int) ([]*Suggestion, error) {
+	counts := make(map[string]uint64)
+	ix.mu.RLock()
+	for _, f := range spellingFields {
+		dict, err := ix.idx.FieldDictPrefix(f, []byte(last))
+		if err != nil {
+			ix.mu.RUnlock()
+			return nil, err
+		}
+		for {
+			e, err := dict.Next()
+			if err != nil || e == nil {
+				break
+			}
+			if e.Term != last {
+				counts[e.Term] += e.Count
+			}
+		}
+		dict.Close()
+	}
+	ix.mu.RUnlock()
+	terms := make([]string, 0, len(counts))
+	for t := range counts {
+		terms = append(terms, t)
+	}
+	slices.SortFunc(terms, func(a, b string) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	prefix := strings.Join(words, " ")
+	if prefix != "" {
+		prefix += " "
+	}
+	ret := make([]*Suggestion, 0, min(limit, len(terms)))
+	for _, t := range terms[:min(limit, len(terms))] {
+		ret = append(ret, &Suggestion{
+			Text:  prefix + t,
+			Kind:  SuggestionTerm,
+			Count: counts[t],
+		})
+	}
+	return ret, nil
+}
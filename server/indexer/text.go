@@ -0,0 +1,527 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	HTMLContentType      = "text/html"
+	PlainTextContentType = "text/plain"
+	MarkdownContentType  = "text/markdown"
+	JSONContentType      = "application/json"
+	// codeContentTypePrefix is followed by the name of the programming language in source code content types
+	codeContentTypePrefix = "text/x-"
+)
+
+// text formats of non-HTML documents
+const (
+	formatPlain    = "plain"
+	formatMarkdown = "markdown"
+	formatJSON     = "json"
+	formatCode     = "code"
+)
+
+// codeExtensions maps source file extensions to language names
+var codeExtensions = map[string]string{
+	".go":     "go",
+	".py":     "python",
+	".js":     "javascript",
+	".mjs":    "javascript",
+	".cjs":    "javascript",
+	".jsx":    "javascript",
+	".ts":     "typescript",
+	".tsx":    "typescript",
+	".rs":     "rust",
+	".c":      "c",
+	".h":      "c",
+	".cc":     "c++",
+	".cpp":    "c++",
+	".hpp":    "c++",
+	".java":   "java",
+	".kt":     "kotlin",
+	".cs":     "csharp",
+	".rb":     "ruby",
+	".php":    "php",
+	".swift":  "swift",
+	".lua":    "lua",
+	".pl":     "perl",
+	".sh":     "shellscript",
+	".bash":   "shellscript",
+	".zsh":    "shellscript",
+	".sql":    "sql",
+	".css":    "css",
+	".scss":   "scss",
+	".svelte": "svelte",
+	".vue":    "vue",
+	".hs":     "haskell",
+	".ex":     "elixir",
+	".erl":    "erlang",
+	".clj":    "clojure",
+	".scala":  "scala",
+	".zig":    "zig",
+	".nix":    "nix",
+	".yaml":   "yaml",
+	".yml":    "yaml",
+	".toml":   "toml",
+	".xml":    "xml",
+}
+
+// codeMediaTypes maps the registered media types of source code to language names
+var codeMediaTypes = map[string]string{
+	"application/javascript":    "javascript",
+	"text/javascript":           "javascript",
+	"application/typescript":    "typescript",
+	"application/x-sh":          "shellscript",
+	"application/x-httpd-php":   "php",
+	"application/sql":           "sql",
+	"text/css":                  "css",
+	"application/yaml":          "yaml",
+	"application/x-yaml":        "yaml",
+	"application/toml":          "toml",
+	"application/xml":           "xml",
+	"text/xml":                  "xml",
+	"application/x-python":      "python",
+	"application/x-ruby":        "ruby",
+	"application/x-perl":        "perl",
+	"application/x-shellscript": "shellscript",
+}
+
+var markdownExtensions = []string{".md", ".markdown", ".mdown", ".mkd"}
+
+type plainTextExtractor struct{}
+
+type markdownExtractor struct{}
+
+type jsonExtractor struct{}
+
+type codeExtractor struct{}
+
+// ContentTypeByName returns the content type of a supported text file based on its extension
+func ContentTypeByName(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	switch {
+	case slices.Contains(markdownExtensions, ext):
+		return MarkdownContentType
+	case ext == ".json":
+		return JSONContentType
+	case ext == ".txt" || ext == ".text" || ext == ".rst" || ext == ".org":
+		return PlainTextContentType
+	case ext == ".pdf":
+		return PDFContentType
+	case ext == ".html" || ext == ".htm":
+		return HTMLContentType
+	}
+	if l, ok := codeExtensions[ext]; ok {
+		return codeContentTypePrefix + l
+	}
+	return ""
+}
+
+// IsSupportedContentType reports whether documents of the given content type
+// downloaded from URL u can be indexed
+func IsSupportedContentType(contentType, u string) bool {
+	d := &Document{URL: u, ContentType: contentType}
+	return d.IsPDF() || d.isHTML() || d.textFormat() != ""
+}
+
+// IsCode reports whether the document contains source code or structured data
+func (d *Document) IsCode() bool {
+	f := d.textFormat()
+	return f == formatCode || f == formatJSON
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
+
+func (d *Document) isHTML() bool {
+	switch mediaType(d.ContentType) {
+	case HTMLContentType, "application/xhtml+xml":
+		return true
+	case "":
+		return !d.IsPDF() && d.Data == "" && d.HTML != ""
+	}
+	return false
+}
+
+// textFormat returns the text format of non-HTML documents or an empty string
+func (d *Document) textFormat() string {
+	if d.IsPDF() || d.isHTML() {
+		return ""
+	}
+	mt := mediaType(d.ContentType)
+	switch {
+	case mt == "application/octet-stream":
+		return d.formatByURL()
+	case mt == "":
+		if d.Data == "" && d.Text == "" {
+			return ""
+		}
+		if f := d.formatByURL(); f != "" {
+			return f
+		}
+		if d.Data == "" {
+			return formatPlain
+		}
+		return ""
+	case mt == MarkdownContentType || mt == "text/x-markdown":
+		return formatMarkdown
+	case mt == JSONContentType || strings.HasSuffix(mt, "+json"):
+		return formatJSON
+	case strings.HasPrefix(mt, codeContentTypePrefix), codeMediaTypes[mt] != "":
+		return formatCode
+	case mt == PlainTextContentType:
+		// raw file hosts serve every text file as text/plain
+		if f := d.formatByURL(); f != "" {
+			return f
+		}
+		return formatPlain
+	case strings.HasPrefix(mt, "text/"):
+		return formatPlain
+	}
+	return ""
+}
+
+func (d *Document) formatByURL() string {
+	pu, err := url.Parse(d.URL)
+	if err != nil {
+		return ""
+	}
+	ct := ContentTypeByName(pu.Path)
+	switch {
+	case ct == MarkdownContentType:
+		return formatMarkdown
+	case ct == JSONContentType:
+		return formatJSON
+	case ct == PlainTextContentType:
+		return formatPlain
+	case strings.HasPrefix(ct, codeContentTypePrefix):
+		return formatCode
+	}
+	return ""
+}
+
+// codeLanguage returns the programming language of source code documents
+func (d *Document) codeLanguage() string {
+	mt := mediaType(d.ContentType)
+	if strings.HasPrefix(mt, codeContentTypePrefix) {
+		return strings.TrimPrefix(mt, codeContentTypePrefix)
+	}
+	if pu, err := url.Parse(d.URL); err == nil {
+		if l, ok := codeExtensions[strings.ToLower(path.Ext(pu.Path))]; ok {
+			return l
+		}
+	}
+	if l, ok := codeMediaTypes[mt]; ok {
+		return l
+	}
+	return "plain"
+}
+
+// textContent returns the submitted raw content of a text document.
+// Content submitted as text is moved to Data to allow extracting it again on reindexing.
+func (d *Document) textContent() (string, error) {
+	if d.Data == "" {
+		d.Data = base64.StdEncoding.EncodeToString([]byte(d.Text))
+		return d.Text, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(d.Data)
+	if err != nil {
+		return "", errors.New("invalid data encoding: " + err.Error())
+	}
+	return strings.ToValidUTF8(string(bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))), ""), nil
+}
+
+// decodeHTMLData moves the content of uploaded HTML files to the HTML field
+func (d *Document) decodeHTMLData() {
+	if d.Data == "" || d.HTML != "" || !d.isHTML() {
+		return
+	}
+	b, err := base64.StdEncoding.DecodeString(d.Data)
+	if err != nil {
+		return
+	}
+	d.HTML = strings.ToValidUTF8(string(b), "")
+	d.Data = ""
+}
+
+// textData returns the decoded raw content of text documents
+func (d *Document) textData() (string, bool) {
+	if d.Data == "" || d.IsPDF() {
+		return "", false
+	}
+	b, err := base64.StdEncoding.DecodeString(d.Data)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// fileName returns the last path element of the document's URL
+func (d *Document) fileName() string {
+	pu, err := url.Parse(d.URL)
+	if err != nil {
+		return ""
+	}
+	n := path.Base(pu.Path)
+	if n == "/" || n == "." {
+		return ""
+	}
+	if un, err := url.PathUnescape(n); err == nil {
+		return un
+	}
+	return n
+}
+
+func (e *plainTextExtractor) Name() string {
+	return "PlainText"
+}
+
+func (e *plainTextExtractor) Match(d *Document) bool {
+	return d.textFormat() == formatPlain
+}
+
+func (e *plainTextExtractor) Extract(d *Document) error {
+	t, err := d.textContent()
+	if err != nil {
+		return err
+	}
+	d.ContentType = PlainTextContentType
+	d.Text = strings.TrimSpace(t)
+	if d.Title == "" {
+		d.Title = d.fileName()
+	}
+	if d.Text == "" && d.Title == "" {
+		return errors.New("no content found")
+	}
+	return nil
+}
+
+var (
+	mdHeadingRe   = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdSetextRe    = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	mdFenceRe     = regexp.MustCompile("^ {0,3}(```|~~~)")
+	mdListRe      = regexp.MustCompile(`^(\s*)(?:[-*+]|\d+[.)])\s+(?:\[[ xX]\]\s+)?`)
+	mdQuoteRe     = regexp.MustCompile(`^\s*(?:>\s?)+`)
+	mdImageRe     = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLinkRe      = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdRefLinkRe   = regexp.MustCompile(`\[([^\]]+)\]\[[^\]]*\]`)
+	mdLinkDefRe   = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s+\S+`)
+	mdEmphasisRe  = regexp.MustCompile(`(\*\*|__|\*|~~)(\S(?:.*?\S)?)(\*\*|__|\*|~~)`)
+	mdCodeSpanRe  = regexp.MustCompile("`+([^`]+)`+")
+	mdRuleRe      = regexp.MustCompile(`^ {0,3}([-*_])(?:\s*([-*_])){2,}\s*$`)
+	mdTitleMetaRe = regexp.MustCompile(`^title:\s*["']?(.*?)["']?\s*$`)
+)
+
+func (e *markdownExtractor) Name() string {
+	return "Markdown"
+}
+
+func (e *markdownExtractor) Match(d *Document) bool {
+	return d.textFormat() == formatMarkdown
+}
+
+func (e *markdownExtractor) Extract(d *Document) error {
+	t, err := d.textContent()
+	if err != nil {
+		return err
+	}
+	title, text, headings := renderMarkdown(t)
+	d.setLinks(markdownLinks(t))
+	d.setHeadings(headings)
+	d.setCode(markdownCode(t))
+	d.ContentType = MarkdownContentType
+	d.Text = text
+	if d.Title == "" {
+		d.Title = title
+	}
+	if d.Title == "" {
+		d.Title = d.fileName()
+	}
+	if d.Text == "" && d.Title == "" {
+		return errors.New("no content found")
+	}
+	return nil
+}
+
+// renderMarkdown converts Markdown to plain text and returns the title and the h1-h4 headings of the document.
+// The title is read from the front matter or from the first top level heading.
+func renderMarkdown(s string) (string, string, []string) {
+	lines := strings.Split(s, "\n")
+	title := ""
+	var headings []string
+	// YAML front matter
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			l := strings.TrimSpace(lines[i])
+			if l == "---" || l == "..." {
+				lines = lines[i+1:]
+				break
+			}
+			if m := mdTitleMetaRe.FindStringSubmatch(l); m != nil && title == "" {
+				title = m[1]
+			}
+		}
+	}
+	out := make([]string, 0, len(lines))
+	inCode := false
+	for i, l := range lines {
+		if mdFenceRe.MatchString(l) {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			out = append(out, l)
+			continue
+		}
+		if m := mdHeadingRe.FindStringSubmatch(l); m != nil {
+			h := renderMarkdownInline(m[2])
+			if title == "" && len(m[1]) == 1 {
+				title = h
+			}
+			if len(m[1]) <= 4 {
+				headings = append(headings, h)
+			}
+			out = append(out, "", h, "")
+			continue
+		}
+		if mdSetextRe.MatchString(l) && i > 0 && strings.TrimSpace(lines[i-1]) != "" && len(out) > 0 {
+			if title == "" && strings.Contains(l, "=") {
+				title = out[len(out)-1]
+			}
+			headings = append(headings, out[len(out)-1])
+			out = append(out, "")
+			continue
+		}
+		if mdRuleRe.MatchString(l) || mdLinkDefRe.MatchString(l) {
+			out = append(out, "")
+			continue
+		}
+		l = mdQuoteRe.ReplaceAllString(l, "")
+		l = mdListRe.ReplaceAllString(l, "$1")
+		out = append(out, renderMarkdownInline(l))
+	}
+	text := strings.Join(out, "\n")
+	for strings.Contains(text, "\n\n\n") {
+		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
+	}
+	return strings.TrimSpace(title), strings.TrimSpace(text), headings
+}
+
+func renderMarkdownInline(s string) string {
+	s = mdImageRe.ReplaceAllString(s, "$1")
+	s = mdLinkRe.ReplaceAllString(s, "$1")
+	s = mdRefLinkRe.ReplaceAllString(s, "$1")
+	s = mdCodeSpanRe.ReplaceAllString(s, "$1")
+	s = mdEmphasisRe.ReplaceAllString(s, "$2")
+	return strings.TrimRight(s, " \t")
+}
+
+func (e *jsonExtractor) Name() string {
+	return "JSON"
+}
+
+func (e *jsonExtractor) Match(d *Document) bool {
+	return d.textFormat() == formatJSON
+}
+
+func (e *jsonExtractor) Extract(d *Document) error {
+	t, err := d.textContent()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(t))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return errors.New("failed to parse JSON: " + err.Error())
+	}
+	var lines []string
+	flattenJSON("", v, &lines)
+	d.ContentType = JSONContentType
+	d.Text = strings.Join(lines, "\n")
+	if d.Title == "" {
+		if o, ok := v.(map[string]any); ok {
+			d.Title = firstNonEmpty(ldString(o["title"]), ldString(o["name"]), ldString(o["headline"]))
+		}
+	}
+	if d.Title == "" {
+		d.Title = d.fileName()
+	}
+	if d.Text == "" && d.Title == "" {
+		return errors.New("no content found")
+	}
+	return nil
+}
+
+// flattenJSON appends a "path: value" line for every scalar value of a JSON document
+func flattenJSON(prefix string, v any, lines *[]string) {
+	switch o := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(o))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := k
+			if prefix != "" {
+				p = prefix + "." + k
+			}
+			flattenJSON(p, o[k], lines)
+		}
+	case []any:
+		for n, e := range o {
+			flattenJSON(fmt.Sprintf("%s[%d]", prefix, n), e, lines)
+		}
+	case nil:
+		return
+	default:
+		s := fmt.Sprint(o)
+		if prefix == "" {
+			*lines = append(*lines, s)
+		} else {
+			*lines = append(*lines, prefix+": "+s)
+		}
+	}
+}
+
+func (e *codeExtractor) Name() string {
+	return "Code"
+}
+
+func (e *codeExtractor) Match(d *Document) bool {
+	return d.textFormat() == formatCode
+}
+
+func (e *codeExtractor) Extract(d *Document) error {
+	t, err := d.textContent()
+	if err != nil {
+		return err
+	}
+	d.ContentType = codeContentTypePrefix + d.codeLanguage()
+	d.Text = strings.Trim(t, "\n")
+	d.setCode([]string{d.Text})
+	if d.Title == "" {
+		d.Title = d.fileName()
+	}
+	if strings.TrimSpace(d.Text) == "" && d.Title == "" {
+		return errors.New("no content found")
+	}
+	return nil
+}
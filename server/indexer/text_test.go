@@ -0,0 +1,132 @@
+package indexer
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		md       string
+		title    string
+		text     string
+		headings []string
+	}{
+		{
+			name:     "atx headings",
+			md:       "# Title\n\nIntro text.\n\n## Section ##\n\nBody\n\n##### Deep",
+			title:    "Title",
+			text:     "Title\n\nIntro text.\n\nSection\n\nBody\n\nDeep",
+			headings: []string{"Title", "Section"},
+		},
+		{
+			name:     "setext headings",
+			md:       "Title\n=====\n\nSub\n---\ntext",
+			title:    "Title",
+			text:     "Title\n\nSub\n\ntext",
+			headings: []string{"Title", "Sub"},
+		},
+		{
+			name:     "front matter title",
+			md:       "---\ntitle: \"Front Matter\"\ndate: 2024-01-01\n---\n# Heading\ntext",
+			title:    "Front Matter",
+			text:     "Heading\n\ntext",
+			headings: []string{"Heading"},
+		},
+		{
+			name:  "inline formatting",
+			md:    "Some **bold**, *em*, ~~del~~ and `code` with [a link](https://example.com) and ![an image](img.png) [ref][1]\n\n[1]: https://example.com",
+			title: "",
+			text:  "Some bold, em, del and code with a link and an image ref",
+		},
+		{
+			name:  "lists and quotes",
+			md:    "- one\n* two\n  + nested\n1. first\n- [x] done\n> quoted\n> > twice",
+			title: "",
+			text:  "one\ntwo\n  nested\nfirst\ndone\nquoted\ntwice",
+		},
+		{
+			name:  "fenced code is kept verbatim",
+			md:    "```go\n# not a heading\n**x**\n```\ntext",
+			title: "",
+			text:  "# not a heading\n**x**\ntext",
+		},
+		{
+			name:  "rules",
+			md:    "a\n\n***\n\n- - -\nb",
+			title: "",
+			text:  "a\n\nb",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			title, text, headings := renderMarkdown(tc.md)
+			if title != tc.title {
+				t.Errorf("title = %q, want %q", title, tc.title)
+			}
+			if text != tc.text {
+				t.Errorf("text = %q, want %q", text, tc.text)
+			}
+			if !slices.Equal(headings, tc.headings) {
+				t.Errorf("headings = %q, want %q", headings, tc.headings)
+			}
+		})
+	}
+}
+
+func TestFlattenJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want []string
+	}{
+		{
+			name: "scalar",
+			json: `"text"`,
+			want: []string{"text"},
+		},
+		{
+			name: "sorted object keys",
+			json: `{"b": 2, "a": "x", "c": true}`,
+			want: []string{"a: x", "b: 2", "c: true"},
+		},
+		{
+			name: "nested",
+			json: `{"user": {"name": "n", "tags": ["a", "b"]}, "items": [{"id": 1}, {"id": 2.50}]}`,
+			want: []string{"items[0].id: 1", "items[1].id: 2.50", "user.name: n", "user.tags[0]: a", "user.tags[1]: b"},
+		},
+		{
+			name: "top level array",
+			json: `[1, "x", [2]]`,
+			want: []string{"[0]: 1", "[1]: x", "[2][0]: 2"},
+		},
+		{
+			name: "null values are skipped",
+			json: `{"a": null, "b": [null], "c": 12345678901234567890}`,
+			want: []string{"c: 12345678901234567890"},
+		},
+		{
+			name: "empty containers",
+			json: `{"a": {}, "b": []}`,
+			want: nil,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := json.NewDecoder(strings.NewReader(tc.json))
+			dec.UseNumber()
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+			var lines []string
+			flattenJSON("", v, &lines)
+			if !slices.Equal(lines, tc.want) {
+				t.Errorf("flattenJSON(%s) = %q, want %q", tc.json, lines, tc.want)
+			}
+		})
+	}
+}
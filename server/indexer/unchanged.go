@@ -0,0 +1,106 @@
+package indexer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+)
+
+// sourceHash returns the hash of the submitted content of the document
+func (d *Document) sourceHash() string {
+	if d.Data != "" {
+		h := sha256.Sum256([]byte(d.Data))
+		return hex.EncodeToString(h[:])
+	}
+	if d.HTML == "" {
+		return d.contentHash()
+	}
+	h := sha256.Sum256([]byte(d.HTML))
+	return hex.EncodeToString(h[:])
+}
+
+// Prepare processes the document unless its content is identical to the
+// indexed version of the same URL. Unchanged documents reuse the already
+// extracted content and only their visit metadata is updated by Add.
+// Bleve can't update single fields, so Add writes the whole document again
+// if its visit statistics or annotations changed, as they are stored in the
+// index for sorting and frecency ranking, and skips the write otherwise.
+func (d *Document) Prepare() error {
+	if d.processed {
+		return nil
+	}
+	if i == nil || d.URL == "" {
+		return d.Process()
+	}
+	d.decodeHTMLData()
+	d.Hash = d.sourceHash()
+	e := GetByURL(d.URL)
+	if e == nil {
+		return d.Process()
+	}
+	d.copyAnnotations(e)
+	d.firstSeen = e.FirstSeen
+	if d.firstSeen == 0 || (e.Added != 0 && e.Added < d.firstSeen) {
+		d.firstSeen = e.Added
+	}
+	if e.Hash != d.Hash {
+		return d.Process()
+	}
+	if err := d.checkSensitive(); err != nil {
+		return err
+	}
+	d.URL = e.URL
+	d.Domain = e.Domain
+	d.Title = e.Title
+	d.Text = e.Text
+	d.Favicon = e.Favicon
+	d.Added = e.Added
+	d.Simhash = e.Simhash
+	d.Language = e.Language
+	d.ContentType = e.ContentType
+	if d.Data != "" {
+		// the stored content is already redacted
+		d.Data = e.Data
+	}
+	d.Author = e.Author
+	d.Published = e.Published
+	d.Meta = e.Meta
+	d.Links = e.Links
+	d.Headings = e.Headings
+	d.Code = e.Code
+	d.Description = e.Description
+	d.SiteName = e.SiteName
+	d.Image = e.Image
+	d.unchanged = true
+	d.indexed = e
+	d.processed = true
+	log.Debug().Str("URL", d.URL).Msg("Document content unchanged")
+	return nil
+}
+
+// keepFirstSeen makes sure that the first seen date of a document is
+// never later than the first seen date of its previously indexed version
+func (d *Document) keepFirstSeen() {
+	if d.firstSeen != 0 && (d.FirstSeen == 0 || d.firstSeen < d.FirstSeen) {
+		d.FirstSeen = d.firstSeen
+	}
+	if d.FirstSeen == 0 {
+		d.FirstSeen = d.Added
+	}
+}
+
+// upToDate reports whether an unchanged document has the same visit statistics
+// and annotations as its indexed version
+func (d *Document) upToDate() bool {
+	e := d.indexed
+	if !d.unchanged || e == nil {
+		return false
+	}
+	return d.Visits == e.Visits &&
+		d.FirstSeen == e.FirstSeen &&
+		d.LastSeen == e.LastSeen &&
+		d.Note == e.Note &&
+		slices.Equal(d.Tags, e.Tags)
+}
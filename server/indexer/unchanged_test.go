@@ -0,0 +1,67 @@
+package indexer
+
+import (
+	"testing"
+
+	"github.com/asciimoo/hister/server/model"
+)
+
+func TestResubmitUnchangedDocument(t *testing.T) {
+	initTestIndex(t)
+	const u = "https://example.com/page"
+	first := addTestDocument(t, u, "Page", "unchanged content")
+	// backdate the indexed version to tell it apart from the resubmissions
+	e := GetByURL(u)
+	e.Added = 1000
+	e.FirstSeen = 1000
+	if err := i.idx.Index(u, e); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := model.AddVisit(u, "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := &Document{URL: u, HTML: first.HTML}
+	if err := Add(d); err != nil {
+		t.Fatal(err)
+	}
+	if !d.unchanged {
+		t.Error("unchanged document is extracted again")
+	}
+	e = GetByURL(u)
+	if e.Added != 1000 {
+		t.Errorf("added = %d, want the date of the indexed version", e.Added)
+	}
+	if e.FirstSeen != 1000 {
+		t.Errorf("first seen = %d, want 1000", e.FirstSeen)
+	}
+	if e.Visits != 2 {
+		t.Errorf("visits = %d, want 2", e.Visits)
+	}
+	if e.Text != "unchanged content" {
+		t.Errorf("text = %q, want the indexed text", e.Text)
+	}
+	if revs, err := model.GetRevisions(u); err != nil || len(revs) != 1 {
+		t.Errorf("revisions = %d, %v, want 1", len(revs), err)
+	}
+
+	if err := model.AddVisit(u, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	addTestDocument(t, u, "Page", "changed content")
+	e = GetByURL(u)
+	if e.Added == 1000 || e.Text != "changed content" {
+		t.Errorf("changed document isn't extracted again: added = %d, text = %q", e.Added, e.Text)
+	}
+	if e.FirstSeen != 1000 {
+		t.Errorf("first seen of the changed document = %d, want 1000", e.FirstSeen)
+	}
+	if e.Visits != 3 {
+		t.Errorf("visits of the changed document = %d, want 3", e.Visits)
+	}
+	if revs, err := model.GetRevisions(u); err != nil || len(revs) != 2 {
+		t.Errorf("revisions = %d, %v, want 2", len(revs), err)
+	}
+}
@@ -0,0 +1,67 @@
+package indexer
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"github.com/asciimoo/hister/config"
+
+	"golang.org/x/net/html"
+)
+
+var urlNormalization *config.URLNormalization
+
+// NormalizeURL returns the canonical form of an URL according to the URL normalization config
+func NormalizeURL(u string) string {
+	if urlNormalization == nil {
+		return u
+	}
+	return urlNormalization.Normalize(u)
+}
+
+// canonicalURL returns the absolute URL of the `<link rel="canonical">` element of the document
+// if it points to the same site
+func (d *Document) canonicalURL() string {
+	base, err := url.Parse(d.URL)
+	if err != nil {
+		return ""
+	}
+	z := html.NewTokenizer(bytes.NewReader([]byte(d.HTML)))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tn, hasAttr := z.TagName()
+			switch string(tn) {
+			case "body":
+				return ""
+			case "link":
+				var rel, href string
+				for hasAttr {
+					var k, v []byte
+					k, v, hasAttr = z.TagAttr()
+					switch string(k) {
+					case "rel":
+						rel = strings.ToLower(string(v))
+					case "href":
+						href = strings.TrimSpace(string(v))
+					}
+				}
+				if rel != "canonical" || href == "" {
+					continue
+				}
+				cu, err := base.Parse(href)
+				if err != nil || (cu.Scheme != "http" && cu.Scheme != "https") {
+					return ""
+				}
+				if strings.TrimPrefix(cu.Hostname(), "www.") != strings.TrimPrefix(base.Hostname(), "www.") {
+					return ""
+				}
+				return cu.String()
+			}
+		}
+	}
+}
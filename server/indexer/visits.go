@@ -0,0 +1,95 @@
+package indexer
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/asciimoo/hister/server/model"
+
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/rs/zerolog/log"
+)
+
+// frecencyWindow is the number of top relevance hits re-ranked by frecency
+const frecencyWindow = 500
+
+// recencyBuckets assigns weights to visits by age, similar to Firefox's frecency algorithm
+var recencyBuckets = []struct {
+	age    time.Duration
+	weight float64
+}{
+	{4 * 24 * time.Hour, 100},
+	{14 * 24 * time.Hour, 70},
+	{31 * 24 * time.Hour, 50},
+	{90 * 24 * time.Hour, 30},
+}
+
+func updateVisitStats(d *Document) {
+	if model.DB == nil {
+		return
+	}
+	s, err := model.GetVisitStats(d.URL)
+	if err != nil {
+		log.Warn().Err(err).Str("URL", d.URL).Msg("Failed to get visit stats")
+		return
+	}
+	if s.Count == 0 {
+		return
+	}
+	d.Visits = int(s.Count)
+	d.FirstSeen = s.FirstSeen
+	d.LastSeen = s.LastSeen
+}
+
+func (d *Document) readVisitFields(h *search.DocumentMatch) {
+	if n, ok := h.Fields["visits"].(float64); ok {
+		d.Visits = int(n)
+	}
+	if t, ok := h.Fields["first_seen"].(float64); ok {
+		d.FirstSeen = int64(t)
+	}
+	if t, ok := h.Fields["last_seen"].(float64); ok {
+		d.LastSeen = int64(t)
+	}
+}
+
+func (d *Document) frecency(now time.Time) float64 {
+	visits := max(d.Visits, 1)
+	lastSeen := d.LastSeen
+	if lastSeen == 0 {
+		lastSeen = d.Added
+	}
+	weight := 10.
+	age := now.Sub(time.Unix(lastSeen, 0))
+	for _, b := range recencyBuckets {
+		if age <= b.age {
+			weight = b.weight
+			break
+		}
+	}
+	return math.Log2(1+float64(visits)) * weight
+}
+
+// rankByFrecency blends the normalized relevance score of the documents with
+// their visit frequency and recency and sorts them by the blended score.
+func rankByFrecency(docs []*Document) {
+	if len(docs) == 0 {
+		return
+	}
+	maxScore := 0.
+	for _, d := range docs {
+		maxScore = max(maxScore, d.Score)
+	}
+	now := time.Now()
+	for _, d := range docs {
+		relevance := 1.
+		if maxScore > 0 {
+			relevance = d.Score / maxScore
+		}
+		d.Score = relevance * (1 + d.frecency(now)/100)
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].Score > docs[j].Score
+	})
+}
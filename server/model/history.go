@@ -36,6 +36,11 @@ type URLCount struct {
 	Count uint   `json:"count"`
 }
 
+type QueryCount struct {
+	Query string `json:"query"`
+	Count uint   `json:"count"`
+}
+
 type HistoryItem struct {
 	Query string `json:"query"`
 	Title string `json:"title"`
@@ -69,7 +74,8 @@ func GetOrCreateHistory(q string) *History {
 	return ret
 }
 
-func DeleteHistoryItem(query, url string) error {
+// DeleteHistoryItem removes the result of a query stored with any of the given URL forms
+func DeleteHistoryItem(query string, urls ...string) error {
 	return DB.Delete(
 		&HistoryLink{},
 		"id in (?)",
@@ -77,7 +83,7 @@ func DeleteHistoryItem(query, url string) error {
 			Select("history_links.id").
 			Joins("JOIN histories ON history_links.history_id = histories.id").
 			Joins("JOIN links ON history_links.link_id = links.id").
-			Where("histories.query = ? and links.url = ?", query, url),
+			Where("histories.query = ? and links.url IN ?", query, urls),
 	).Error
 }
 
@@ -136,3 +142,20 @@ func GetQuerySuggestion(q string) string {
 		Limit(1).Find(&r)
 	return r
 }
+
+// GetQuerySuggestions returns the past queries starting with the prefix, most used first
+func GetQuerySuggestions(prefix string, limit int) ([]*QueryCount, error) {
+	var qs []*QueryCount
+	err := DB.Select("histories.query as query, SUM(history_links.count) as count").
+		Table("history_links").
+		Joins("JOIN histories ON history_links.history_id = histories.id").
+		Where(`LOWER(histories.query) LIKE ? ESCAPE '\'`, escapeLike(strings.ToLower(prefix))+"%").
+		Group("histories.query").
+		Order("count DESC, MAX(history_links.updated_at) DESC").
+		Limit(limit).Find(&qs).Error
+	return qs, err
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
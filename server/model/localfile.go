@@ -0,0 +1,62 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package model
+
+import (
+	"strings"
+)
+
+// LocalFile is an indexed file of a local directory.
+type LocalFile struct {
+	CommonFields
+	Path    string `gorm:"uniqueIndex" json:"path"`
+	ModTime int64  `json:"mod_time"`
+	Size    int64  `json:"size"`
+}
+
+// GetLocalFiles returns the indexed files of a directory and its subdirectories by path.
+func GetLocalFiles(dir string) (map[string]*LocalFile, error) {
+	var fs []*LocalFile
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	// SQLite counts the length of the prefix in characters, LIKE would ignore the case of the paths
+	err := DB.Where("substr(path, 1, length(?)) = ?", prefix, prefix).Find(&fs).Error
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]*LocalFile, len(fs))
+	for _, f := range fs {
+		ret[f.Path] = f
+	}
+	return ret, nil
+}
+
+// GetLocalFile returns the record of an indexed file or nil if the file is not indexed.
+func GetLocalFile(path string) (*LocalFile, error) {
+	var fs []*LocalFile
+	if err := DB.Where("path = ?", path).Limit(1).Find(&fs).Error; err != nil {
+		return nil, err
+	}
+	if len(fs) == 0 {
+		return nil, nil
+	}
+	return fs[0], nil
+}
+
+// SaveLocalFile records the modification time and the size of an indexed file.
+func SaveLocalFile(path string, modTime, size int64) error {
+	var f LocalFile
+	if err := DB.Where("path = ?", path).Limit(1).Find(&f).Error; err != nil {
+		return err
+	}
+	f.Path = path
+	f.ModTime = modTime
+	f.Size = size
+	return DB.Save(&f).Error
+}
+
+// DeleteLocalFile removes the record of an indexed file.
+func DeleteLocalFile(path string) error {
+	return DB.Where("path = ?", path).Delete(&LocalFile{}).Error
+}
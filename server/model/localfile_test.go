@@ -0,0 +1,58 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package model
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/asciimoo/hister/config"
+)
+
+func TestGetLocalFiles(t *testing.T) {
+	c := config.CreateDefaultConfig()
+	c.Server.Database = filepath.Join(t.TempDir(), "db.sqlite3")
+	if err := Init(c); err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{
+		"/home/user/docs/a.md",
+		"/home/user/docs/sub/b.md",
+		"/home/user/docs2/c.md",
+		"/home/user/Docs/d.md",
+		"/home/user/dokumentumok/é/e.md",
+		"/home/user/dokumentumok/éé/f.md",
+		"/home/user/100%/g.md",
+		"/home/user/100a/h.md",
+	}
+	for _, p := range paths {
+		if err := SaveLocalFile(p, 1, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{"/home/user/docs", []string{"/home/user/docs/a.md", "/home/user/docs/sub/b.md"}},
+		{"/home/user/docs/", []string{"/home/user/docs/a.md", "/home/user/docs/sub/b.md"}},
+		{"/home/user/Docs", []string{"/home/user/Docs/d.md"}},
+		{"/home/user/dokumentumok/é", []string{"/home/user/dokumentumok/é/e.md"}},
+		{"/home/user/100%", []string{"/home/user/100%/g.md"}},
+		{"/home/user/missing", nil},
+	}
+	for _, tc := range tests {
+		fs, err := GetLocalFiles(tc.dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := slices.Sorted(maps.Keys(fs))
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("GetLocalFiles(%q) = %v, want %v", tc.dir, got, tc.want)
+		}
+	}
+}
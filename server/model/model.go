@@ -80,6 +80,9 @@ func automigrate() error {
 		&Link{},
 		&HistoryLink{},
 		&IndexerVersion{},
+		&Revision{},
+		&Visit{},
+		&LocalFile{},
 	)
 }
 
@@ -0,0 +1,117 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// MaxRevisions is the number of revisions kept per URL, older ones are pruned
+const MaxRevisions = 20
+
+// Revision is a snapshot of a document's content at a given visit.
+type Revision struct {
+	CommonFields
+	URL   string `gorm:"index" json:"url"`
+	Hash  string `gorm:"index" json:"hash"`
+	Title string `json:"title"`
+	Text  string `json:"text,omitempty"`
+	HTML  string `json:"html,omitempty"`
+}
+
+// AddRevision stores a new revision of the given URL unless its latest
+// revision has the same content hash. It reports whether a new revision was created.
+func AddRevision(u, hash, title, text, html string) (bool, error) {
+	var latest Revision
+	err := DB.Select("id, hash").
+		Where("url = ?", u).
+		Order("id DESC").
+		Limit(1).Find(&latest).Error
+	if err != nil {
+		return false, err
+	}
+	if latest.ID != 0 && latest.Hash == hash {
+		return false, nil
+	}
+	r := &Revision{
+		URL:   u,
+		Hash:  hash,
+		Title: title,
+		Text:  text,
+		HTML:  html,
+	}
+	if err := DB.Create(r).Error; err != nil {
+		return false, err
+	}
+	return true, pruneRevisions(u)
+}
+
+// pruneRevisions removes the oldest revisions of a URL exceeding MaxRevisions
+func pruneRevisions(u string) error {
+	var ids []uint
+	err := DB.Model(&Revision{}).
+		Where("url = ?", u).
+		Order("id DESC").
+		Offset(MaxRevisions).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return DB.Delete(&Revision{}, ids).Error
+}
+
+// GetRevisions returns the revisions of a URL without their content, oldest first.
+func GetRevisions(u string) ([]*Revision, error) {
+	var rs []*Revision
+	err := DB.Select("id, created_at, updated_at, url, hash, title").
+		Where("url = ?", u).
+		Order("id ASC").
+		Find(&rs).Error
+	return rs, err
+}
+
+// GetRevision returns a single revision of a URL including its content.
+func GetRevision(u string, id uint) (*Revision, error) {
+	var r *Revision
+	err := DB.Where("url = ? AND id = ?", u, id).First(&r).Error
+	return r, err
+}
+
+// GetLatestRevision returns the most recent revision of a URL including its content.
+func GetLatestRevision(u string) (*Revision, error) {
+	var r *Revision
+	err := DB.Where("url = ?", u).Order("id DESC").First(&r).Error
+	return r, err
+}
+
+// DeleteRevisions removes every stored revision of a URL.
+func DeleteRevisions(u string) error {
+	return DB.Where("url = ?", u).Delete(&Revision{}).Error
+}
+
+// IterateRevisions calls fn with every stored revision including its content.
+func IterateRevisions(fn func(*Revision) error) error {
+	var rs []*Revision
+	return DB.FindInBatches(&rs, 100, func(_ *gorm.DB, _ int) error {
+		for _, r := range rs {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// GetRevisionContents returns the revisions of a URL including their content, oldest first.
+func GetRevisionContents(u string) ([]*Revision, error) {
+	var rs []*Revision
+	err := DB.Where("url = ?", u).Order("id ASC").Find(&rs).Error
+	return rs, err
+}
+
+// UpdateRevision saves the modified content of a revision.
+func UpdateRevision(r *Revision) error {
+	return DB.Save(r).Error
+}
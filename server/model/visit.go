@@ -0,0 +1,57 @@
+// SPDX-FileContributor: Adam Tauber <asciimoo@gmail.com>
+//
+// SPDX-License-Identifier: AGPLv3+
+
+package model
+
+// Visit is a single submission of a URL.
+type Visit struct {
+	CommonFields
+	URL      string `gorm:"index" json:"url"`
+	Source   string `json:"source"`
+	Referrer string `json:"referrer"`
+}
+
+// VisitStats summarizes the visit log of a URL.
+type VisitStats struct {
+	Count     int64
+	FirstSeen int64
+	LastSeen  int64
+}
+
+func AddVisit(u, source, referrer string) error {
+	return DB.Create(&Visit{
+		URL:      u,
+		Source:   source,
+		Referrer: referrer,
+	}).Error
+}
+
+func GetVisitStats(u string) (*VisitStats, error) {
+	s := &VisitStats{}
+	if err := DB.Model(&Visit{}).Where("url = ?", u).Count(&s.Count).Error; err != nil {
+		return nil, err
+	}
+	if s.Count == 0 {
+		return s, nil
+	}
+	var first, last Visit
+	if err := DB.Select("created_at").Where("url = ?", u).Order("id ASC").First(&first).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Select("created_at").Where("url = ?", u).Order("id DESC").First(&last).Error; err != nil {
+		return nil, err
+	}
+	s.FirstSeen = first.CreatedAt.Unix()
+	s.LastSeen = last.CreatedAt.Unix()
+	return s, nil
+}
+
+// MoveVisits reassigns the visits of a URL to another URL.
+func MoveVisits(from, to string) error {
+	return DB.Model(&Visit{}).Where("url = ?", from).Update("url", to).Error
+}
+
+func DeleteVisits(u string) error {
+	return DB.Where("url = ?", u).Delete(&Visit{}).Error
+}
@@ -3,13 +3,17 @@ package server
 import (
 	"bufio"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"html/template"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,10 +34,17 @@ var (
 	fs              http.Handler
 	sessionStore    *sessions.CookieStore
 	errCSRFMismatch = errors.New("CSRF token mismatch")
+	errFileTooLarge = fmt.Errorf("file is larger than %d bytes", indexer.MaxFileSize)
 	storeName       = "hister"
 	tokName         = "csrf_token"
 )
 
+// maxUploadMemory is the size of uploaded files kept in memory, larger files are stored in temporary files
+const maxUploadMemory = 32 << 20
+
+// maxUploadSize is the size limit of upload requests, the largest indexed file and the other form fields
+const maxUploadSize = indexer.MaxFileSize + 1<<20
+
 type tArgs map[string]any
 
 type historyItem struct {
@@ -43,6 +54,18 @@ type historyItem struct {
 	Delete bool   `json:"delete"`
 }
 
+type addRequest struct {
+	*indexer.Document
+	Source   string `json:"source"`
+	Referrer string `json:"referrer"`
+}
+
+type addResponse struct {
+	URL       string                    `json:"url"`
+	Error     string                    `json:"error,omitempty"`
+	Sensitive []*indexer.SensitiveMatch `json:"sensitive,omitempty"`
+}
+
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -76,6 +99,17 @@ var tFns = template.FuncMap{
 	"Join":       func(s []string, delim string) string { return strings.Join(s, delim) },
 	"Replace":    strings.ReplaceAll,
 	"ToLower":    strings.ToLower,
+	"DiffClass": func(l string) string {
+		switch {
+		case strings.HasPrefix(l, "+++"), strings.HasPrefix(l, "---"), strings.HasPrefix(l, "@@"):
+			return "grey"
+		case strings.HasPrefix(l, "+"):
+			return "success"
+		case strings.HasPrefix(l, "-"):
+			return "error"
+		}
+		return ""
+	},
 	"Truncate": func(s string, maxLen int) string {
 		if len(s) > maxLen {
 			return s[:maxLen] + "[..]"
@@ -108,6 +142,7 @@ func init() {
 	addTemplate("api", "layout/base.tpl", "api.tpl")
 	addTemplate("about", "layout/base.tpl", "about.tpl")
 	addTemplate("history", "layout/base.tpl", "history.tpl")
+	addTemplate("revisions", "layout/base.tpl", "revisions.tpl")
 	addTemplate("opensearch", "opensearch.tpl")
 }
 
@@ -264,7 +299,22 @@ func serveSearch(c *webContext) {
 		// Go uses a reference time (2006-01-02 15:04:05)
 		// "2006-01-02" = YYYY-MM-DD format
 		// Very weird...
-		query := &indexer.Query{Text: q}
+		query := &indexer.Query{
+			Text:          q,
+			Sort:          c.Request.URL.Query().Get("sort"),
+			FacetInterval: c.Request.URL.Query().Get("facet_interval"),
+			Highlight:     c.Request.URL.Query().Get("highlight"),
+			After:         c.Request.URL.Query().Get("after"),
+		}
+		query.Facets, _ = strconv.ParseBool(c.Request.URL.Query().Get("facets"))
+		query.NoCorrection, _ = strconv.ParseBool(c.Request.URL.Query().Get("no_correction"))
+		for param, field := range map[string]*int{"limit": &query.Limit, "offset": &query.Offset, "fragments": &query.Fragments, "fragment_size": &query.FragmentSize} {
+			if v := c.Request.URL.Query().Get(param); v != "" {
+				if n, err := strconv.Atoi(v); err == nil {
+					*field = n
+				}
+			}
+		}
 		for param, field := range map[string]*int64{"date_from": &query.DateFrom, "date_to": &query.DateTo} {
 			if v := c.Request.URL.Query().Get(param); v != "" {
 				if t, err := time.Parse("2006-01-02", v); err == nil {
@@ -274,6 +324,10 @@ func serveSearch(c *webContext) {
 		}
 		r, err := doSearch(query, c.Config)
 		if err != nil {
+			if errors.Is(err, indexer.ErrInvalidQuery) {
+				http.Error(c.Response, err.Error(), http.StatusBadRequest)
+				return
+			}
 			fmt.Println(err)
 			serve500(c)
 			return
@@ -308,7 +362,7 @@ func serveSearch(c *webContext) {
 		res, err := doSearch(query, c.Config)
 		if err != nil {
 			log.Error().Err(err).Msg("search error")
-			continue
+			res = &indexer.Results{Query: query, Error: err.Error()}
 		}
 		jr, err := json.Marshal(res)
 		if err != nil {
@@ -327,17 +381,23 @@ func doSearch(query *indexer.Query, cfg *config.Config) (*indexer.Results, error
 	query.Text = cfg.Rules.ResolveAliases(query.Text)
 	res, err := indexer.Search(cfg, query)
 	if err != nil {
+		if errors.Is(err, indexer.ErrInvalidQuery) {
+			return nil, err
+		}
 		log.Error().Err(err).Msg("failed to get indexer results")
 	}
 	if res == nil {
 		res = &indexer.Results{}
 	}
-	hr, err := model.GetURLsByQuery(oq)
-	if err == nil && len(hr) > 0 {
-		res.History = hr
-	}
-	if oq != "" {
-		res.QuerySuggestion = model.GetQuerySuggestion(oq)
+	// history items and suggestions belong to the first page only
+	if query.Offset == 0 && query.After == "" {
+		hr, err := model.GetURLsByQuery(oq)
+		if err == nil && len(hr) > 0 {
+			res.History = hr
+		}
+		if oq != "" {
+			res.QuerySuggestion = model.GetQuerySuggestion(oq)
+		}
 	}
 	duration := float32(time.Since(start).Milliseconds()) / 1000.
 	res.SearchDuration = fmt.Sprintf("%.3f seconds", duration)
@@ -355,17 +415,29 @@ func serveAdd(c *webContext) {
 		return
 	}
 	d := &indexer.Document{}
+	ar := &addRequest{Document: d}
 	jsonData := false
 	if strings.Contains(c.Request.Header.Get("Content-Type"), "json") {
 		jsonData = true
-		err := json.NewDecoder(c.Request.Body).Decode(d)
+		err := json.NewDecoder(c.Request.Body).Decode(ar)
 		if err != nil {
 			serve500(c)
 			return
 		}
 	} else {
-		err := c.Request.ParseForm()
+		var err error
+		if strings.HasPrefix(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
+			c.Request.Body = http.MaxBytesReader(c.Response, c.Request.Body, maxUploadSize)
+			err = c.Request.ParseMultipartForm(maxUploadMemory)
+		} else {
+			err = c.Request.ParseForm()
+		}
 		if err != nil {
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				http.Error(c.Response, errFileTooLarge.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
 			serve500(c)
 			return
 		}
@@ -373,13 +445,41 @@ func serveAdd(c *webContext) {
 		d.URL = f.Get("url")
 		d.Title = f.Get("title")
 		d.Text = f.Get("text")
+		d.ContentType = f.Get("content_type")
+		ar.Source = f.Get("source")
+		ar.Referrer = f.Get("referrer")
+		if c.Request.MultipartForm != nil {
+			if err := readUploadedFile(c.Request, d); err != nil {
+				status := http.StatusBadRequest
+				if errors.Is(err, errFileTooLarge) {
+					status = http.StatusRequestEntityTooLarge
+				}
+				http.Error(c.Response, err.Error(), status)
+				return
+			}
+		}
+	}
+	if ar.Source == "" {
+		ar.Source = visitSource(c.Request)
 	}
 	if !c.Config.Rules.IsSkip(d.URL) && !strings.HasPrefix(d.URL, c.Config.BaseURL("/")) {
-		if err := d.Process(); err != nil {
+		if err := d.Prepare(); err != nil {
+			if jsonData && errors.Is(err, indexer.ErrSensitiveContent) {
+				log.Debug().Err(err).Str("URL", d.URL).Msg("document rejected")
+				c.JSONStatus(http.StatusUnprocessableEntity, &addResponse{
+					URL:       d.URL,
+					Error:     err.Error(),
+					Sensitive: d.SensitiveMatches(),
+				})
+				return
+			}
 			log.Error().Err(err).Str("URL", d.URL).Msg("failed to process document")
 			serve500(c)
 			return
 		}
+		if err := model.AddVisit(d.URL, ar.Source, ar.Referrer); err != nil {
+			log.Warn().Err(err).Str("URL", d.URL).Msg("failed to record visit")
+		}
 		err := indexer.Add(d)
 		log.Debug().Str("URL", d.URL).Msg("item added to index")
 		if err != nil {
@@ -387,6 +487,13 @@ func serveAdd(c *webContext) {
 			serve500(c)
 			return
 		}
+		if jsonData {
+			c.JSONStatus(http.StatusCreated, &addResponse{
+				URL:       d.URL,
+				Sensitive: d.SensitiveMatches(),
+			})
+			return
+		}
 		c.Response.WriteHeader(http.StatusCreated)
 	} else {
 		log.Debug().Str("url", d.URL).Msg("skip indexing")
@@ -398,6 +505,17 @@ func serveAdd(c *webContext) {
 	c.Render("add", nil)
 }
 
+func visitSource(r *http.Request) string {
+	o := r.Header.Get("Origin")
+	switch {
+	case o == "hister://":
+		return "cli"
+	case strings.HasPrefix(o, "moz-extension://"), strings.HasPrefix(o, "chrome-extension://"):
+		return "extension"
+	}
+	return "web"
+}
+
 func serveHistory(c *webContext) {
 	m := c.Request.Method
 	if m == http.MethodGet {
@@ -421,13 +539,16 @@ func serveHistory(c *webContext) {
 		serve500(c)
 		return
 	}
+	rawURL := strings.TrimSpace(h.URL)
+	h.URL = indexer.NormalizeURL(rawURL)
 	if h.Delete {
-		if err := model.DeleteHistoryItem(h.Query, h.URL); err != nil {
+		// items added before URL normalization are stored with the raw URL
+		if err := model.DeleteHistoryItem(h.Query, h.URL, rawURL); err != nil {
 			serve500(c)
 		}
 		return
 	}
-	err = model.UpdateHistory(strings.TrimSpace(h.Query), strings.TrimSpace(h.URL), strings.TrimSpace(h.Title))
+	err = model.UpdateHistory(strings.TrimSpace(h.Query), h.URL, strings.TrimSpace(h.Title))
 	if err != nil {
 		log.Error().Err(err).Msg("failed to update history")
 		serve500(c)
@@ -481,6 +602,17 @@ func serveReadable(c *webContext) {
 		serve500(c)
 		return
 	}
+	if doc.HTML == "" {
+		content := textToHTML(doc.Text)
+		if doc.IsCode() {
+			content = "<pre><code>" + html.EscapeString(doc.Text) + "</code></pre>"
+		}
+		c.JSON(map[string]string{
+			"title":   doc.Title,
+			"content": content,
+		})
+		return
+	}
 	pu, err := url.Parse(u)
 	if err != nil {
 		serve500(c)
@@ -503,6 +635,162 @@ func serveReadable(c *webContext) {
 	})
 }
 
+// textToHTML renders extracted plain text, pages are separated by form feed characters
+func textToHTML(text string) string {
+	var sb strings.Builder
+	for n, page := range strings.Split(text, "\f") {
+		if n > 0 {
+			sb.WriteString("<hr />")
+		}
+		for _, p := range strings.Split(page, "\n\n") {
+			if p = strings.TrimSpace(p); p != "" {
+				sb.WriteString("<p>" + html.EscapeString(p) + "</p>")
+			}
+		}
+	}
+	return sb.String()
+}
+
+func serveBacklinks(c *webContext) {
+	u := c.Request.URL.Query().Get("url")
+	if u == "" {
+		http.Error(c.Response, "missing url", http.StatusBadRequest)
+		return
+	}
+	bs, err := indexer.Backlinks(u)
+	if err != nil {
+		serve500(c)
+		return
+	}
+	c.JSON(bs)
+}
+
+func serveRevisions(c *webContext) {
+	u := c.Request.URL.Query().Get("url")
+	rs, err := indexer.Revisions(u)
+	if err != nil {
+		serve500(c)
+		return
+	}
+	c.JSON(rs)
+}
+
+func serveDocumentTags(c *webContext) {
+	if err := c.Request.ParseForm(); err != nil {
+		serve500(c)
+		return
+	}
+	f := c.Request.PostForm
+	u := f.Get("url")
+	d, err := indexer.UpdateTags(u, strings.Split(f.Get("add"), ","), strings.Split(f.Get("remove"), ","))
+	if err != nil {
+		serveAnnotationError(c, u, err)
+		return
+	}
+	c.JSON(map[string]any{
+		"url":  d.URL,
+		"tags": d.Tags,
+	})
+}
+
+func serveDocumentNote(c *webContext) {
+	if err := c.Request.ParseForm(); err != nil {
+		serve500(c)
+		return
+	}
+	u := c.Request.PostForm.Get("url")
+	d, err := indexer.SetNote(u, c.Request.PostForm.Get("note"))
+	if err != nil {
+		serveAnnotationError(c, u, err)
+		return
+	}
+	c.JSON(map[string]any{
+		"url":  d.URL,
+		"note": d.Note,
+	})
+}
+
+func serveAnnotationError(c *webContext, u string, err error) {
+	if errors.Is(err, indexer.ErrDocumentNotFound) {
+		http.Error(c.Response, err.Error(), http.StatusNotFound)
+		return
+	}
+	log.Error().Err(err).Str("URL", u).Msg("failed to update document annotations")
+	serve500(c)
+}
+
+func serveRevisionDiff(c *webContext) {
+	u := c.Request.URL.Query().Get("url")
+	from, to, err := parseRevisionRange(c.Request.URL.Query())
+	if err != nil {
+		http.Error(c.Response, err.Error(), http.StatusBadRequest)
+		return
+	}
+	d, err := indexer.DiffRevisions(u, from, to)
+	if err != nil {
+		if errors.Is(err, indexer.ErrNoRevision) {
+			http.Error(c.Response, err.Error(), http.StatusNotFound)
+			return
+		}
+		serve500(c)
+		return
+	}
+	c.JSON(d)
+}
+
+func serveRevisionsPage(c *webContext) {
+	u := c.Request.URL.Query().Get("url")
+	rs, err := indexer.Revisions(u)
+	if err != nil {
+		serve500(c)
+		return
+	}
+	from, to, err := parseRevisionRange(c.Request.URL.Query())
+	if err != nil {
+		http.Error(c.Response, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from == 0 && len(rs) > 1 {
+		from = rs[len(rs)-2].ID
+	}
+	args := tArgs{
+		"URL":       u,
+		"Revisions": rs,
+		"From":      from,
+		"To":        to,
+	}
+	if from != 0 {
+		d, err := indexer.DiffRevisions(u, from, to)
+		if err != nil {
+			if errors.Is(err, indexer.ErrNoRevision) {
+				http.Error(c.Response, err.Error(), http.StatusNotFound)
+				return
+			}
+			serve500(c)
+			return
+		}
+		args["Diff"] = strings.Split(d.Diff, "\n")
+		args["To"] = d.To.ID
+	}
+	c.Render("revisions", args)
+}
+
+func parseRevisionRange(q url.Values) (uint, uint, error) {
+	var r [2]uint
+	for i, k := range []string{"from", "to"} {
+		v := q.Get(k)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid revision id %q", v)
+		}
+		r[i] = uint(n)
+	}
+	return r[0], r[1], nil
+}
+
 func serveHelp(c *webContext) {
 	c.Render("help", nil)
 }
@@ -522,6 +810,66 @@ func serveOpensearch(c *webContext) {
 	c.Render("opensearch", nil)
 }
 
+func serveSuggestions(c *webContext) {
+	q := c.Request.URL.Query().Get("q")
+	limit, _ := strconv.Atoi(c.Request.URL.Query().Get("limit"))
+	ss, err := indexer.Suggest(q, limit)
+	if err != nil {
+		serve500(c)
+		return
+	}
+	if c.Request.URL.Query().Get("format") == "json" {
+		if ss == nil {
+			ss = []*indexer.Suggestion{}
+		}
+		c.JSON(ss)
+		return
+	}
+	// [query, completions, descriptions, URLs]
+	texts := make([]string, len(ss))
+	descs := make([]string, len(ss))
+	urls := make([]string, len(ss))
+	for n, s := range ss {
+		texts[n] = s.Text
+		descs[n] = s.Kind
+		if s.URL != "" {
+			urls[n] = s.URL
+		} else {
+			urls[n] = c.Config.BaseURL("/?q=" + url.QueryEscape(s.Text))
+		}
+	}
+	c.Response.Header().Add("Content-Type", "application/x-suggestions+json")
+	json.NewEncoder(c.Response).Encode([]any{q, texts, descs, urls})
+}
+
+// readUploadedFile stores the content of the uploaded "file" form field in the document
+func readUploadedFile(r *http.Request, d *indexer.Document) error {
+	f, h, err := r.FormFile("file")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	if h.Size > indexer.MaxFileSize {
+		return errFileTooLarge
+	}
+	b, err := io.ReadAll(io.LimitReader(f, indexer.MaxFileSize))
+	if err != nil {
+		return err
+	}
+	if d.ContentType == "" {
+		d.ContentType = h.Header.Get("Content-Type")
+	}
+	// browsers don't know the media type of most text formats
+	if ct := indexer.ContentTypeByName(h.Filename); ct != "" && (d.ContentType == "" || strings.HasPrefix(d.ContentType, "application/octet-stream")) {
+		d.ContentType = ct
+	}
+	d.Data = base64.StdEncoding.EncodeToString(b)
+	return nil
+}
+
 func serveAddAlias(c *webContext) {
 	err := c.Request.ParseForm()
 	if err != nil {
@@ -573,6 +921,86 @@ func serveDeleteDocument(c *webContext) {
 	serve200(c)
 }
 
+func serveReindexStatus(c *webContext) {
+	c.JSON(indexer.ReindexProgress())
+}
+
+func serveStartReindex(c *webContext) {
+	if err := c.Request.ParseForm(); err != nil {
+		serve500(c)
+		return
+	}
+	excludeSensitive, _ := strconv.ParseBool(c.Request.PostForm.Get("exclude_sensitive"))
+	workers, _ := strconv.Atoi(c.Request.PostForm.Get("workers"))
+	opts := &indexer.ReindexOptions{
+		SkipSensitiveChecks: excludeSensitive,
+		Workers:             workers,
+	}
+	if err := indexer.StartReindex(c.Config, opts); err != nil {
+		if errors.Is(err, indexer.ErrReindexRunning) {
+			http.Error(c.Response, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to start reindex")
+		serve500(c)
+		return
+	}
+	c.JSON(indexer.ReindexProgress())
+}
+
+func splitPatterns(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func serveAudit(c *webContext) {
+	res, err := indexer.Audit(splitPatterns(c.Request.URL.Query().Get("patterns")))
+	if err != nil {
+		if errors.Is(err, indexer.ErrUnknownPattern) {
+			http.Error(c.Response, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to audit documents")
+		serve500(c)
+		return
+	}
+	c.JSON(res)
+}
+
+func serveAuditFix(c *webContext) {
+	if err := c.Request.ParseForm(); err != nil {
+		serve500(c)
+		return
+	}
+	f := c.Request.PostForm
+	u := f.Get("url")
+	var err error
+	switch f.Get("action") {
+	case "delete":
+		err = indexer.Delete(u)
+	case "redact":
+		err = indexer.Redact(u, splitPatterns(f.Get("patterns")))
+	default:
+		http.Error(c.Response, "invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, indexer.ErrUnknownPattern):
+			http.Error(c.Response, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, indexer.ErrDocumentNotFound):
+			http.Error(c.Response, err.Error(), http.StatusNotFound)
+		default:
+			log.Error().Err(err).Str("URL", u).Msg("Failed to fix audited document")
+			serve500(c)
+		}
+		return
+	}
+	serve200(c)
+}
+
 func serveFavicon(c *webContext) {
 	i, err := static.FS.ReadFile("favicon.ico")
 	if err != nil {
@@ -632,3 +1060,9 @@ func (c *webContext) JSON(o any) {
 	c.Response.Header().Add("Content-Type", "application/json")
 	json.NewEncoder(c.Response).Encode(o)
 }
+
+func (c *webContext) JSONStatus(code int, o any) {
+	c.Response.Header().Add("Content-Type", "application/json")
+	c.Response.WriteHeader(code)
+	json.NewEncoder(c.Response).Encode(o)
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/asciimoo/hister/config"
+	"github.com/asciimoo/hister/server/indexer"
+	"github.com/asciimoo/hister/server/model"
+)
+
+// initTestServer initializes the index and the database in a temporary data directory
+func initTestServer(t *testing.T) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HISTER_DATA_DIR", dir)
+	cfg, err := config.Load(filepath.Join(dir, "config.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.SensitiveContentPatterns = map[string]string{
+		"secret": `SECRET-\d+`,
+		"token":  `tok_[a-z]+`,
+		"email":  `[a-z]+@corp\.com`,
+	}
+	cfg.SensitiveContentActions = map[string]*config.SensitiveContentAction{
+		"token": {Action: config.SensitiveRedact},
+		"email": {Action: config.SensitiveAllowOnDomains, Domains: []string{"corp.com"}},
+	}
+	if err := model.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestServeAddSensitiveContent(t *testing.T) {
+	cfg := initTestServer(t)
+	tests := []struct {
+		url     string
+		text    string
+		status  int
+		indexed string
+		match   indexer.SensitiveMatch
+	}{
+		{
+			url:    "https://example.com/rejected",
+			text:   "key SECRET-1",
+			status: http.StatusUnprocessableEntity,
+			match:  indexer.SensitiveMatch{Pattern: "secret", Action: config.SensitiveReject, Count: 1},
+		},
+		{
+			url:     "https://example.com/redacted",
+			text:    "token tok_abc",
+			status:  http.StatusCreated,
+			indexed: "token [REDACTED:token]",
+			match:   indexer.SensitiveMatch{Pattern: "token", Action: config.SensitiveRedact, Count: 1},
+		},
+		{
+			url:     "https://wiki.corp.com/allowed",
+			text:    "mail bob@corp.com",
+			status:  http.StatusCreated,
+			indexed: "mail bob@corp.com",
+			match:   indexer.SensitiveMatch{Pattern: "email", Action: "allow", Count: 1},
+		},
+		{
+			url:    "https://example.com/not-allowed",
+			text:   "mail bob@corp.com",
+			status: http.StatusUnprocessableEntity,
+			match:  indexer.SensitiveMatch{Pattern: "email", Action: config.SensitiveReject, Count: 1},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.url, func(t *testing.T) {
+			body, err := json.Marshal(map[string]string{
+				"url":  tc.url,
+				"html": "<html><head><title>Page</title></head><body><p>" + tc.text + "</p></body></html>",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/add", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			serveAdd(&webContext{Request: req, Response: rec, Config: cfg})
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.status)
+			}
+			res := &addResponse{}
+			if err := json.NewDecoder(rec.Body).Decode(res); err != nil {
+				t.Fatal(err)
+			}
+			if res.URL != tc.url {
+				t.Errorf("url = %q, want %q", res.URL, tc.url)
+			}
+			if (res.Error != "") != (tc.status == http.StatusUnprocessableEntity) {
+				t.Errorf("error = %q", res.Error)
+			}
+			if len(res.Sensitive) != 1 || *res.Sensitive[0] != tc.match {
+				t.Errorf("sensitive = %+v, want [%+v]", res.Sensitive, tc.match)
+			}
+			d := indexer.GetByURL(tc.url)
+			if tc.indexed == "" {
+				if d != nil {
+					t.Error("rejected document is indexed")
+				}
+				return
+			}
+			if d == nil {
+				t.Fatal("document isn't indexed")
+			}
+			if d.Text != tc.indexed {
+				t.Errorf("indexed text = %q, want %q", d.Text, tc.indexed)
+			}
+		})
+	}
+}
+
+func TestServeAddUpload(t *testing.T) {
+	cfg := initTestServer(t)
+	tests := []struct {
+		name    string
+		content []byte
+		status  int
+	}{
+		{name: "text file", content: []byte("uploaded notes"), status: http.StatusCreated},
+		{name: "file over the size limit", content: make([]byte, indexer.MaxFileSize+1), status: http.StatusRequestEntityTooLarge},
+		{name: "request over the size limit", content: make([]byte, maxUploadSize), status: http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			u := "https://example.com/" + strings.ReplaceAll(tc.name, " ", "-") + ".txt"
+			body := &bytes.Buffer{}
+			w := multipart.NewWriter(body)
+			if err := w.WriteField("url", u); err != nil {
+				t.Fatal(err)
+			}
+			fw, err := w.CreateFormFile("file", "notes.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fw.Write(tc.content); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/add", body)
+			req.Header.Set("Content-Type", w.FormDataContentType())
+			rec := httptest.NewRecorder()
+			serveAdd(&webContext{Request: req, Response: rec, Config: cfg})
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.status)
+			}
+			d := indexer.GetByURL(u)
+			if tc.status != http.StatusCreated {
+				if d != nil {
+					t.Error("rejected upload is indexed")
+				}
+				return
+			}
+			if d == nil || d.Text != string(tc.content) {
+				t.Errorf("indexed document = %+v, want the uploaded content", d)
+			}
+		})
+	}
+}
@@ -17,6 +17,15 @@ export interface SearchMessage {
   date_from?: number;
   date_to?: number;
   highlight?: string;
+  fragments?: number;
+  fragment_size?: number;
+}
+
+export interface Snippet {
+  field: string;
+  text: string;
+  offset: number;
+  matches: { start: number; end: number }[];
 }
 
 export interface SearchResult {
@@ -27,15 +36,64 @@ export interface SearchResult {
   text?: string;
   favicon?: string;
   added?: number;
+  visits?: number;
+  first_seen?: number;
+  last_seen?: number;
+  duplicates?: string[];
+  tags?: string[];
+  note?: string;
+  content_type?: string;
+  author?: string;
+  published?: number;
+  page?: number;
+  anchor?: string;
+  code?: string;
+  fragments?: string[];
+  snippets?: Snippet[];
+  meta?: Record<string, string>;
+  description?: string;
+  site_name?: string;
+  image?: string;
+}
+
+export interface TermFacet {
+  term: string;
+  count: number;
+}
+
+export interface DateFacet {
+  name: string;
+  from: number;
+  to: number;
+  count: number;
+}
+
+export interface Facets {
+  domains: TermFacet[];
+  dates: DateFacet[];
+}
+
+export interface Suggestion {
+  text: string;
+  kind: "query" | "document" | "term";
+  url?: string;
+  count: number;
 }
 
 export interface SearchResults {
   documents?: SearchResult[];
   history?: SearchResult[];
   total?: number;
+  offset?: number;
+  next_offset?: number;
+  next?: string;
+  has_more?: boolean;
+  error?: string;
   search_duration?: string;
   query?: { text: string };
   query_suggestion?: string;
+  spelling?: { query: string; applied: boolean };
+  facets?: Facets;
 }
 
 export function escapeHTML(s: string): string {
@@ -64,6 +122,51 @@ export function formatTimestamp(unixTimestamp: number): string {
     .split(".")[0];
 }
 
+export function formatDate(unixTimestamp: number): string {
+  return formatTimestamp(unixTimestamp).split(" ")[0];
+}
+
+function plural(n: string, word: string): string {
+  return n === "1" ? `${n} ${word}` : `${n} ${word}s`;
+}
+
+// formatMeta summarizes the structured fields of site specific extractors
+export function formatMeta(meta?: Record<string, string>): string {
+  if (!meta) return "";
+  const parts: string[] = [];
+  if (meta.repo) parts.push(meta.issue ? `${meta.repo} #${meta.issue}` : meta.repo);
+  if (meta.subreddit) parts.push(`r/${meta.subreddit}`);
+  if (meta.section) parts.push(meta.section);
+  if (meta.state) parts.push(meta.state);
+  if (meta.score) parts.push(plural(meta.score, "point"));
+  if (meta.answers) parts.push(plural(meta.answers, "answer") + (meta.accepted === "true" ? " (accepted)" : ""));
+  if (meta.comments) parts.push(plural(meta.comments, "comment"));
+  return parts.join(" · ");
+}
+
+const contentTypeNames: Record<string, string> = {
+  "application/pdf": "PDF",
+  "text/plain": "text",
+  "text/markdown": "Markdown",
+  "application/json": "JSON",
+};
+
+// formatContentType returns a short label of non-HTML content types
+export function formatContentType(contentType?: string): string {
+  if (!contentType || contentType === "text/html") return "";
+  if (contentTypeNames[contentType]) return contentTypeNames[contentType];
+  return contentType.replace(/^text\/x-/, "");
+}
+
+// formatByline summarizes the site, author and publication date of a result
+export function formatByline(r: SearchResult): string {
+  const parts: string[] = [];
+  if (r.site_name && r.site_name !== r.domain) parts.push(r.site_name);
+  if (r.author) parts.push(`by ${r.author}`);
+  if (r.published) parts.push(formatDate(r.published));
+  return parts.join(" · ");
+}
+
 export function formatRelativeTime(unixTimestamp: number): string {
   if (!unixTimestamp) return "";
 
@@ -321,6 +424,12 @@ export interface QueryParams {
   date_from?: number;
   date_to?: number;
   highlight?: string;
+  fragments?: number;
+  fragment_size?: number;
+  facets?: boolean;
+  facet_interval?: string;
+  after?: string;
+  no_correction?: boolean;
 }
 
 export function buildSearchQuery(
@@ -328,10 +437,17 @@ export function buildSearchQuery(
   sort?: string,
   dateFrom?: string,
   dateTo?: string,
+  facetInterval?: string,
+  after?: string,
+  noCorrection?: boolean,
 ): QueryParams {
   return {
     text,
+    ...(noCorrection && { no_correction: true }),
     highlight: "HTML",
+    facets: !after,
+    ...(after && { after }),
+    ...(facetInterval && { facet_interval: facetInterval }),
     ...(sort && { sort }),
     ...(dateFrom && {
       date_from: Math.floor(new Date(dateFrom).getTime() / 1000),
@@ -1,10 +1,11 @@
 {{define "main"}}
 <div class="container">
-    <form method="post">
+    <form method="post" enctype="multipart/form-data">
         <input type="text" placeholder="URL..." name="url" class="full-width" /><br />
         <input type="text" placeholder="Title..." name="title" class="full-width" /><br />
         <input type="hidden" id="csrf_token" name="csrf_token" value="{{ .CSRF }}" />
         <textarea placeholder="Text..." name="text" class="full-width"></textarea>
+        <label>File: <input type="file" name="file" /></label><br />
         <input type="submit" value="Add" />
     </form>
 </div>
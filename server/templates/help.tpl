@@ -9,8 +9,16 @@
 <p>Use <kbd>*</kbd> for wildcard matches.</p>
 <p>Prefix words or phrases with <kbd>-</kbd> to exclude matching documents.</p>
 <p>Use <code>url:</code> prefix to search only in the URL field.</p>
+<p>GitHub, Stack Overflow, Reddit, Hacker News and MDN pages can be filtered by their structured fields using <code>repo:</code>, <code>issue:</code>, <code>state:</code>, <code>kind:</code>, <code>subreddit:</code>, <code>accepted:</code> and <code>topic:</code> prefixes.</p>
+<p>Use <code>author:</code> prefix to search the author of articles and <code>published:</code> to filter by publication date: <code>published:2024</code>, <code>published:2024-05</code>, <code>published:&gt;2023-06-30</code>, <code>published:&lt;2020</code> or <code>published:2022..2023-03</code>.</p>
+<p>Use <code>type:</code> prefix to filter by content type: <code>type:html</code>, <code>type:pdf</code>, <code>type:text</code>, <code>type:markdown</code>, <code>type:json</code>, <code>type:code</code> or a programming language like <code>type:go</code>.</p>
+<p>Use <code>heading:</code> prefix to search the section headings of pages. Opening a result jumps to the section or the passage of the best match.</p>
+<p>Use <code>code:</code> prefix to search code blocks and inline code. Identifiers, dotted names and dashed flags are kept intact: <code>code:os.Getenv</code>, <code>code:"--max-old-space-size"</code>.</p>
+<p>Use <code>links:URL</code> to find the pages linking to a URL and <code>linkedfrom:URL</code> to find the visited pages linked from a URL.</p>
 <h3>Examples</h3>
 <p><code>"free software" url:*wikipedia.org*</code>: Search for the phrase "free software" only in URLs containing wikipedia.org.</p>
+<p><code>panic repo:asciimoo/hister state:open</code>: Search open issues of the asciimoo/hister GitHub repository containing "panic".</p>
+<p><code>rust author:"jane doe" published:&gt;2023</code>: Search articles about "rust" written by Jane Doe and published after 2023.</p>
 <p><code>golang template -url:*stackoverflow*</code>: Search sites containing both "golang" and "template" but the website's URL should not contain "stackoverflow".</p>
 <h2>Search Aliases</h2>
 <p>Queries can become long and complex quickly. Aliases can be defined in the <a href="/rules">rules</a> page to shorten common query parts.</p>
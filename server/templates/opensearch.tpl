@@ -6,5 +6,5 @@
 	<Image width="16" height="16">{{ .Config.BaseURL "/favicon.ico" }}</Image>
 	<Query role="example" searchTerms="gpl"/>
 	<Url type="text/html" template="{{ .Config.BaseURL "/" }}?q={searchTerms}" />
-	<!-- <Url type="application/x-suggestions+json" rel="suggestions" template="{{ .Config.BaseURL "/suggestions" }}?q={searchTerms}"/> -->
+	<Url type="application/x-suggestions+json" rel="suggestions" template="{{ .Config.BaseURL "/suggestions" }}?q={searchTerms}"/>
 </OpenSearchDescription>
@@ -0,0 +1,31 @@
+{{define "main"}}
+<div class="container full-width">
+<h1>Revisions</h1>
+<p><a href="{{ .URL }}">{{ .URL }}</a></p>
+{{ if .Revisions }}
+<form method="get" action="/revisions">
+    <input type="hidden" name="url" value="{{ .URL }}" />
+    <table>
+        <tr><th>From</th><th>To</th><th>Date</th><th>Title</th></tr>
+        {{ range .Revisions }}
+        <tr>
+            <td><input type="radio" name="from" value="{{ .ID }}" {{ if eq .ID $.From }}checked{{ end }} /></td>
+            <td><input type="radio" name="to" value="{{ .ID }}" {{ if eq .ID $.To }}checked{{ end }} /></td>
+            <td>{{ FormatTime .CreatedAt }}</td>
+            <td>{{ .Title }}</td>
+        </tr>
+        {{ end }}
+    </table>
+    <input type="submit" value="Compare" />
+</form>
+{{ if .Diff }}
+<pre class="diff">{{ range .Diff }}<span class="{{ DiffClass . }}">{{ . }}</span>
+{{ end }}</pre>
+{{ else if gt (len .Revisions) 1 }}
+<p class="grey">No differences</p>
+{{ end }}
+{{ else }}
+<p class="grey">No revisions found</p>
+{{ end }}
+</div>
+{{ end }}
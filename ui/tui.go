@@ -12,6 +12,7 @@ import (
 	"github.com/asciimoo/hister/server/indexer"
 	"github.com/asciimoo/hister/server/model"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -50,6 +51,7 @@ var (
 	selTitle     = lipgloss.NewStyle().Bold(true).Foreground(blue)
 	grayStyle    = lipgloss.NewStyle().Foreground(gray)
 	secTextStyle = lipgloss.NewStyle().Foreground(lightGray).Faint(true).Italic(true)
+	codeStyle    = lipgloss.NewStyle().Foreground(lightGray)
 	dialogStyle  = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(red).Padding(1, 2)
 	helpStyle    = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(blue).Padding(1, 2)
 	statusStyle  = lipgloss.NewStyle().Foreground(white)
@@ -82,6 +84,8 @@ var (
 				Background(bgSelected)
 )
 
+const pageSize = 10
+
 type viewState int
 
 const (
@@ -99,10 +103,16 @@ type searchQuery struct {
 	Text      string `json:"text"`
 	Highlight string `json:"highlight"`
 	Limit     int    `json:"limit"`
+	After     string `json:"after,omitempty"`
+	Facets    bool   `json:"facets"`
 }
 
 type resultsMsg struct{ results *indexer.Results }
 type errMsg struct{ err error }
+type suggestionsMsg struct {
+	query       string
+	suggestions []*indexer.Suggestion
+}
 type wsConnectedMsg struct{ conn *websocket.Conn }
 type wsDisconnectedMsg struct{ err error }
 type reconnectMsg struct{}
@@ -115,7 +125,6 @@ type tuiModel struct {
 	cfg           *config.Config
 	results       *indexer.Results
 	selectedIdx   int
-	limit         int
 	width, height int
 	ready         bool
 	lineOffsets   []int
@@ -135,13 +144,15 @@ func initialModel(cfg *config.Config) *tuiModel {
 	ti.Focus()
 	ti.CharLimit = 200
 	ti.Width = 50
+	ti.ShowSuggestions = true
+	// tab is bound to toggle_focus, right only accepts suggestions at the end of the query
+	ti.KeyMap.AcceptSuggestion = key.NewBinding(key.WithKeys("right"))
 	return &tuiModel{
 		textInput:   ti,
 		state:       stateInput,
 		prevState:   stateInput,
 		cfg:         cfg,
 		selectedIdx: -1,
-		limit:       10,
 		wsChan:      make(chan tea.Msg, 10),
 		wsDone:      make(chan struct{}),
 	}
@@ -209,7 +220,15 @@ func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, cmd
 		}
 	case resultsMsg:
-		m.results = msg.results
+		if m.isNextPage(msg.results) {
+			m.results.Documents = append(m.results.Documents, msg.results.Documents...)
+			m.results.Offset = msg.results.Offset
+			m.results.NextOffset = msg.results.NextOffset
+			m.results.Next = msg.results.Next
+			m.results.HasMore = msg.results.HasMore
+		} else {
+			m.results = msg.results
+		}
 		if m.selectedIdx >= m.getTotalResults() {
 			m.selectedIdx = m.getTotalResults() - 1
 		}
@@ -233,6 +252,18 @@ func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tea.Tick(2*time.Second, func(_ time.Time) tea.Msg { return reconnectMsg{} })
 	case reconnectMsg:
 		return m, m.connectWebSocket()
+	case suggestionsMsg:
+		if msg.query != m.textInput.Value() {
+			return m, nil
+		}
+		texts := make([]string, 0, len(msg.suggestions))
+		for _, s := range msg.suggestions {
+			if s.Kind != indexer.SuggestionDocument {
+				texts = append(texts, s.Text)
+			}
+		}
+		m.textInput.SetSuggestions(texts)
+		return m, nil
 	case errMsg:
 		return m, m.listenToWebSocket()
 	}
@@ -265,10 +296,10 @@ func (m *tuiModel) handleInputKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 	var cmd tea.Cmd
 	oldVal := m.textInput.Value()
+	m.textInput.KeyMap.AcceptSuggestion.SetEnabled(m.textInput.Position() == len([]rune(oldVal)))
 	m.textInput, cmd = m.textInput.Update(msg)
 	if m.textInput.Value() != oldVal {
-		m.limit = 10
-		return m, tea.Batch(cmd, m.search())
+		return m, tea.Batch(cmd, m.search("", pageSize), m.suggest(m.textInput.Value()))
 	}
 	return m, cmd
 }
@@ -301,12 +332,9 @@ func (m *tuiModel) handleResultsKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	case "open_result":
-		if m.selectedIdx == m.limit {
-			m.limit += 10
-			m.viewport.SetContent(m.renderResults())
-			m.scrollToSelected()
-			return m, m.search()
-		} else if u := m.getSelectedURL(); u != "" {
+		if m.selectedIdx == m.loadMoreIdx() {
+			return m, m.search(m.results.Next, pageSize)
+		} else if u := m.getSelectedLink(); u != "" {
 			browser.OpenURL(u)
 		}
 		return m, nil
@@ -388,7 +416,7 @@ func (m *tuiModel) View() string {
 	result := strings.Join(sections, "\n")
 
 	if m.state == stateHelp {
-		help := helpStyle.Render(generateHelpText(m.cfg))
+		help := helpStyle.Render(generateHelpText(m.cfg, m.textInput.KeyMap))
 		return lipgloss.Place(m.width-1, m.height, lipgloss.Center, lipgloss.Center, help)
 	}
 	if m.state == stateDialog {
@@ -399,18 +427,20 @@ func (m *tuiModel) View() string {
 	return result
 }
 
-func generateHelpText(cfg *config.Config) string {
+func generateHelpText(cfg *config.Config, km textinput.KeyMap) string {
 	bindings := make(map[string][]string)
 	for k, v := range cfg.Hotkeys.TUI {
 		bindings[v] = append(bindings[v], k)
 	}
-	fmtAct := func(action, label string) string {
-		keys := bindings[action]
+	fmtKeys := func(keys []string, label string) string {
 		if len(keys) == 0 {
 			return ""
 		}
 		return fmt.Sprintf("  %-20s %s", strings.Join(keys, ", "), label)
 	}
+	fmtAct := func(action, label string) string {
+		return fmtKeys(bindings[action], label)
+	}
 	lines := []string{"Configured Shortcuts:\n", "General:"}
 	for _, a := range []struct{ act, lbl string }{
 		{"quit", "Quit application"}, {"toggle_help", "Toggle this help"},
@@ -423,6 +453,17 @@ func generateHelpText(cfg *config.Config) string {
 	if s := fmtAct("toggle_focus", "Go to results list"); s != "" {
 		lines = append(lines, s)
 	}
+	for _, b := range []struct {
+		keys []string
+		lbl  string
+	}{
+		{km.AcceptSuggestion.Keys(), "Accept suggestion at the end of the query"},
+		{km.NextSuggestion.Keys(), "Next suggestion"}, {km.PrevSuggestion.Keys(), "Previous suggestion"},
+	} {
+		if s := fmtKeys(b.keys, b.lbl); s != "" {
+			lines = append(lines, s)
+		}
+	}
 	lines = append(lines, "\nResults Mode:")
 	for _, a := range []struct{ act, lbl string }{
 		{"toggle_focus", "Go back to input"}, {"scroll_up", "Navigate up"},
@@ -463,6 +504,10 @@ func (m *tuiModel) renderStatusBar() string {
 }
 
 func (m *tuiModel) renderResults() string {
+	if m.results != nil && m.results.Error != "" {
+		m.lineOffsets, m.totalLines = nil, 0
+		return discStyle.Render("Error: " + m.results.Error)
+	}
 	if m.results == nil || (len(m.results.Documents) == 0 && len(m.results.History) == 0) {
 		m.lineOffsets, m.totalLines = nil, 0
 		if m.textInput.Value() != "" {
@@ -476,10 +521,21 @@ func (m *tuiModel) renderResults() string {
 
 	w := max(1, m.viewport.Width-2)
 	style := lipgloss.NewStyle().MaxWidth(w)
-	for _, h := range m.results.History {
-		if currentIdx >= m.limit {
-			break
+	if f := m.renderFacets(); f != "" {
+		item := style.Render(f)
+		items = append(items, item)
+		currentLine += lipgloss.Height(item)
+	}
+	if sp := m.results.Spelling; sp != nil {
+		label := "Did you mean: " + sp.Query + "?"
+		if sp.Applied {
+			label = "No results found, showing results for: " + sp.Query
 		}
+		item := style.Render(histStyle.Render(label))
+		items = append(items, item)
+		currentLine += lipgloss.Height(item)
+	}
+	for _, h := range m.results.History {
 		lineOffsets = append(lineOffsets, currentLine)
 		item := style.Render(m.renderHistoryItem(h, currentIdx == m.selectedIdx))
 		items = append(items, item)
@@ -487,24 +543,21 @@ func (m *tuiModel) renderResults() string {
 		currentIdx++
 	}
 	for _, d := range m.results.Documents {
-		if currentIdx >= m.limit {
-			break
-		}
 		lineOffsets = append(lineOffsets, currentLine)
 		item := style.Render(m.renderDocument(d, currentIdx == m.selectedIdx))
 		items = append(items, item)
 		currentLine += lipgloss.Height(item)
 		currentIdx++
 	}
-	totalItems := len(m.results.History) + len(m.results.Documents)
-	if totalItems > m.limit {
+	if m.results.HasMore {
 		lineOffsets = append(lineOffsets, currentLine)
-		rem := max(0, int(m.results.Total)+len(m.results.History)-m.limit)
+		rem := max(0, int(m.results.Total)-len(m.results.Documents))
+		label := fmt.Sprintf("[ ▼ Load %d more results (%d remaining in index) ]", min(pageSize, rem), rem)
 		var content string
 		if currentIdx == m.selectedIdx {
-			content = loadMoreSelectedStyle.Render(fmt.Sprintf("[ ▼ Load 10 more results (%d remaining in index) ]", rem))
+			content = loadMoreSelectedStyle.Render(label)
 		} else {
-			content = loadMoreStyle.Render(fmt.Sprintf("[ ▼ Load 10 more results (%d remaining in index) ]", rem))
+			content = loadMoreStyle.Render(label)
 		}
 		var item string
 		if currentIdx == m.selectedIdx {
@@ -519,6 +572,17 @@ func (m *tuiModel) renderResults() string {
 	return strings.Join(items, "\n")
 }
 
+func (m *tuiModel) renderFacets() string {
+	if m.results.Facets == nil || len(m.results.Facets.Domains) == 0 {
+		return ""
+	}
+	ds := make([]string, 0, len(m.results.Facets.Domains))
+	for _, f := range m.results.Facets.Domains {
+		ds = append(ds, fmt.Sprintf("%s (%d)", f.Term, f.Count))
+	}
+	return itemStyle.Render(grayStyle.Render("Domains: " + strings.Join(ds, " · ")))
+}
+
 func (m *tuiModel) renderHistoryItem(h *model.URLCount, sel bool) string {
 	ts := titleStyle
 	if sel {
@@ -541,11 +605,35 @@ func (m *tuiModel) renderDocument(d *indexer.Document, sel bool) string {
 	sb.WriteString(ts.Render(strings.Join(strings.Fields(d.Title), " ")))
 	sb.WriteString("\n")
 	sb.WriteString(urlStyle.Render(d.URL))
+	if bl := d.Byline(); bl != "" {
+		sb.WriteString("\n")
+		sb.WriteString(secTextStyle.Render(bl))
+	}
+	if ms := d.MetaSummary(); ms != "" {
+		sb.WriteString("\n")
+		sb.WriteString(secTextStyle.Render(ms))
+	}
 	if d.Text != "" {
 		sb.WriteString("\n")
 		sb.WriteString(secTextStyle.Render("└ "))
 		sb.WriteString(secTextStyle.Render(strings.Join(strings.Fields(d.Text), " ")))
 	}
+	for _, l := range codeSnippet(d.Code, 3) {
+		sb.WriteString("\n")
+		sb.WriteString(codeStyle.Render("│ " + l))
+	}
+	if len(d.Tags) > 0 || d.Note != "" {
+		sb.WriteString("\n")
+		if len(d.Tags) > 0 {
+			sb.WriteString(urlStyle.Render("#" + strings.Join(d.Tags, " #")))
+			sb.WriteString(" ")
+		}
+		sb.WriteString(secTextStyle.Render(strings.Join(strings.Fields(d.Note), " ")))
+	}
+	if len(d.Duplicates) > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(secTextStyle.Render(fmt.Sprintf("also seen at %d other URL(s): %s", len(d.Duplicates), strings.Join(d.Duplicates, ", "))))
+	}
 
 	if sel {
 		return selectedItemStyle.Render(sb.String())
@@ -553,6 +641,24 @@ func (m *tuiModel) renderDocument(d *indexer.Document, sel bool) string {
 	return itemStyle.Render(sb.String())
 }
 
+// codeSnippet returns the non-empty lines of a highlighted code fragment
+// starting from the first line containing a match
+func codeSnippet(code string, maxLines int) []string {
+	var lines []string
+	for _, l := range strings.Split(code, "\n") {
+		if strings.TrimSpace(l) != "" {
+			lines = append(lines, strings.TrimRight(l, " \t"))
+		}
+	}
+	for n, l := range lines {
+		if strings.Contains(l, "\x1b[") {
+			lines = lines[n:]
+			break
+		}
+	}
+	return lines[:min(len(lines), maxLines)]
+}
+
 func (m *tuiModel) renderScrollbar() string {
 	maxScroll := m.totalLines - m.viewport.Height
 	pct := 0.0
@@ -596,14 +702,29 @@ func (m *tuiModel) getTotalResults() int {
 		return 0
 	}
 	c := len(m.results.History) + len(m.results.Documents)
-	if c > m.limit {
-		return m.limit + 1
+	if m.results.HasMore {
+		return c + 1
 	}
 	return c
 }
 
+// loadMoreIdx returns the index of the "load more" item
+func (m *tuiModel) loadMoreIdx() int {
+	if m.results == nil || !m.results.HasMore {
+		return -1
+	}
+	return len(m.results.History) + len(m.results.Documents)
+}
+
+func (m *tuiModel) isNextPage(res *indexer.Results) bool {
+	if res.Offset == 0 || m.results == nil || m.results.Query == nil || res.Query == nil {
+		return false
+	}
+	return res.Query.Text == m.results.Query.Text && res.Offset == m.results.NextOffset
+}
+
 func (m *tuiModel) getSelectedURL() string {
-	if m.results == nil || m.selectedIdx < 0 || m.selectedIdx == m.limit {
+	if m.results == nil || m.selectedIdx < 0 || m.selectedIdx == m.loadMoreIdx() {
 		return ""
 	}
 	if m.selectedIdx < len(m.results.History) {
@@ -616,6 +737,15 @@ func (m *tuiModel) getSelectedURL() string {
 	return ""
 }
 
+// getSelectedLink returns the URL of the selected result pointing to the page of the match if it is known
+func (m *tuiModel) getSelectedLink() string {
+	docIdx := m.selectedIdx - len(m.results.History)
+	if u := m.getSelectedURL(); u == "" || docIdx < 0 || docIdx >= len(m.results.Documents) {
+		return u
+	}
+	return m.results.Documents[docIdx].Link()
+}
+
 func (m *tuiModel) connectWebSocket() tea.Cmd {
 	return func() tea.Msg {
 		wsURL := m.cfg.WebSocketURL()
@@ -646,7 +776,7 @@ func (m *tuiModel) connectWebSocket() tea.Cmd {
 					if err := json.Unmarshal(data, &res); err != nil {
 						continue
 					}
-					if len(res.Documents) == 0 && len(res.History) == 0 {
+					if len(res.Documents) == 0 && len(res.History) == 0 && res.Error == "" {
 						res = &indexer.Results{}
 					}
 					select {
@@ -661,7 +791,8 @@ func (m *tuiModel) connectWebSocket() tea.Cmd {
 	}
 }
 
-func (m *tuiModel) search() tea.Cmd {
+// search sends the query to the server, after is the cursor of the requested page
+func (m *tuiModel) search(after string, limit int) tea.Cmd {
 	return func() tea.Msg {
 		if !m.wsReady || m.conn == nil {
 			return nil
@@ -670,7 +801,7 @@ func (m *tuiModel) search() tea.Cmd {
 		if qt == "" {
 			return resultsMsg{results: &indexer.Results{}}
 		}
-		b, err := json.Marshal(searchQuery{Text: qt, Highlight: "tui", Limit: m.limit + 1})
+		b, err := json.Marshal(searchQuery{Text: qt, Highlight: "tui", Limit: limit, After: after, Facets: after == ""})
 		if err != nil {
 			return nil
 		}
@@ -679,6 +810,33 @@ func (m *tuiModel) search() tea.Cmd {
 	}
 }
 
+// suggest fetches the completions of the query from the suggestions endpoint
+func (m *tuiModel) suggest(q string) tea.Cmd {
+	return func() tea.Msg {
+		if strings.TrimSpace(q) == "" {
+			return suggestionsMsg{query: q}
+		}
+		req, err := http.NewRequest("GET", m.cfg.BaseURL("/suggestions?format=json&q="+url.QueryEscape(q)), nil)
+		if err != nil {
+			return nil
+		}
+		req.Header.Set("Origin", "hister://")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil
+		}
+		var ss []*indexer.Suggestion
+		if err := json.NewDecoder(resp.Body).Decode(&ss); err != nil {
+			return nil
+		}
+		return suggestionsMsg{query: q, suggestions: ss}
+	}
+}
+
 func (m *tuiModel) deleteURL(u string) tea.Cmd {
 	return func() tea.Msg {
 		formData := url.Values{"url": {u}}
@@ -686,7 +844,11 @@ func (m *tuiModel) deleteURL(u string) tea.Cmd {
 		req.Header.Set("Origin", "hister://")
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		http.DefaultClient.Do(req)
-		return m.search()()
+		n := pageSize
+		if m.results != nil {
+			n = max(n, len(m.results.Documents))
+		}
+		return m.search("", n)()
 	}
 }
 